@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+
+	"inv/internal/config"
+	"inv/internal/handlers"
+	"inv/internal/logging"
+	"inv/internal/middlewares"
+	"inv/internal/storage"
+	"inv/internal/testdb"
+)
+
+func TestUploadsShedReadsServed(t *testing.T) {
+	db := testdb.Open(t)
+	cfg := config.LoadConfig(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	h, err := handlers.New(db, storage.NewDB(db), logging.Discard, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	owner := fmt.Sprintf("test-shed-%d", time.Now().UnixNano())
+	t.Cleanup(func() { db.Exec(`DELETE FROM files WHERE owner_id = $1`, owner) })
+
+	var saturated atomic.Bool
+	stats := func() sql.DBStats {
+		if saturated.Load() {
+			return sql.DBStats{InUse: 10}
+		}
+		return sql.DBStats{}
+	}
+	shedder := middlewares.NewLoadShedder(logging.Discard, stats, 10, 0, time.Second)
+	mux := newMux(h, cfg, logging.Discard, shedder.Shed)
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		p := middlewares.Principal{Subject: owner}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r.WithContext(middlewares.WithPrincipal(r.Context(), p)))
+		return rec
+	}
+	upload := func() *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("file", "shed.txt")
+		part.Write([]byte("stored before the load"))
+		mw.Close()
+		r := httptest.NewRequest(http.MethodPost, "/add", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		return serve(r)
+	}
+
+	rec := upload()
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d %q, want 201", rec.Code, rec.Body.String())
+	}
+	id := rec.Body.String()[strings.LastIndex(rec.Body.String(), " ")+1:]
+
+	saturated.Store(true)
+	if rec := upload(); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("upload under load status = %d, want 503", rec.Code)
+	}
+	rec = serve(httptest.NewRequest(http.MethodGet, "/files/"+id, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "stored before the load" {
+		t.Errorf("read under load = %d %q, want 200 with the content", rec.Code, rec.Body.String())
+	}
+}
+
+// selfSignedTLS returns a server config with a certificate for 127.0.0.1
+// and a pool trusting it
+func selfSignedTLS(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+func TestHTTP3(t *testing.T) {
+	serverTLS, roots := selfSignedTLS(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "served over "+r.Proto)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no UDP: %v", err)
+	}
+	h3 := &http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(serverTLS)}
+	go h3.Serve(conn)
+	t.Cleanup(func() { h3.Close() })
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	// The TCP server advertises HTTP/3 once the QUIC listener is up
+	ts := httptest.NewUnstartedServer(advertiseHTTP3(h3, handler))
+	ts.TLS = serverTLS
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	tcpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	want := fmt.Sprintf(`h3=":%d"`, port)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := tcpClient.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		altSvc := resp.Header.Get("Alt-Svc")
+		if strings.Contains(altSvc, want) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Alt-Svc = %q, want it to contain %s", altSvc, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tr := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	t.Cleanup(func() { tr.Close() })
+	resp, err := (&http.Client{Transport: tr, Timeout: 5 * time.Second}).Get(fmt.Sprintf("https://127.0.0.1:%d/", port))
+	if err != nil {
+		t.Fatalf("HTTP/3 request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 3 {
+		t.Errorf("response = %d over %s, want 200 over HTTP/3", resp.StatusCode, resp.Proto)
+	}
+	if string(body) != "served over HTTP/3.0" {
+		t.Errorf("body = %q, want %q", body, "served over HTTP/3.0")
+	}
+}
@@ -0,0 +1,127 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidKey is returned for unknown or revoked keys
+var ErrInvalidKey = errors.New("invalid api key")
+
+// ErrNotFound is returned by Revoke for unknown key ids
+var ErrNotFound = errors.New("api key not found")
+
+// lastUsedResolution is how stale last_used_at may get before Lookup
+// refreshes it, so busy keys don't cost a write per request
+const lastUsedResolution = time.Minute
+
+// Key describes an active API key
+type Key struct {
+	Label  string
+	Scopes []string
+}
+
+// Info describes a key for management, never its plaintext or hash
+type Info struct {
+	ID         int64      `json:"id"`
+	Label      string     `json:"label"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Revoked    bool       `json:"revoked"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Hash returns the hex encoded SHA-256 of a plaintext key
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create issues a new key for label granting scopes and returns its plaintext.
+// Only the hash is stored, so the plaintext can't be recovered later.
+func Create(ctx context.Context, db *sql.DB, label string, scopes ...string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate key: %w", err)
+	}
+	key := base64.RawURLEncoding.EncodeToString(buf)
+
+	_, err := db.ExecContext(ctx, `INSERT INTO api_keys (key_hash, label, scopes) VALUES ($1, $2, $3)`,
+		Hash(key), label, strings.Join(scopes, " "))
+	if err != nil {
+		return "", fmt.Errorf("insert key: %w", err)
+	}
+	return key, nil
+}
+
+// Lookup returns the active key matching the plaintext key
+func Lookup(ctx context.Context, db *sql.DB, key string) (Key, error) {
+	var (
+		k        Key
+		id       int64
+		scopes   string
+		revoked  bool
+		lastUsed sql.NullTime
+	)
+	err := db.QueryRowContext(ctx, `SELECT id, label, scopes, revoked, last_used_at FROM api_keys WHERE key_hash = $1`, Hash(key)).
+		Scan(&id, &k.Label, &scopes, &revoked, &lastUsed)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && revoked) {
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("lookup key: %w", err)
+	}
+	k.Scopes = strings.Fields(scopes)
+
+	// Best effort, a failed bookkeeping write doesn't fail the request
+	if !lastUsed.Valid || time.Since(lastUsed.Time) > lastUsedResolution {
+		db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	}
+	return k, nil
+}
+
+// List returns every key, newest first
+func List(ctx context.Context, db *sql.DB) ([]Info, error) {
+	rows, err := db.QueryContext(ctx, `
+        SELECT id, label, scopes, created_at, revoked, last_used_at
+        FROM api_keys
+        ORDER BY id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("list keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []Info{}
+	for rows.Next() {
+		var (
+			k      Info
+			scopes string
+		)
+		if err := rows.Scan(&k.ID, &k.Label, &scopes, &k.CreatedAt, &k.Revoked, &k.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("list keys: %w", err)
+		}
+		k.Scopes = strings.Fields(scopes)
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke disables the key with the given id, revoking twice is not an error
+func Revoke(ctx context.Context, db *sql.DB, id int64) error {
+	res, err := db.ExecContext(ctx, `UPDATE api_keys SET revoked = TRUE WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("revoke key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
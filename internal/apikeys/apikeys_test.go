@@ -0,0 +1,80 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"inv/internal/testdb"
+)
+
+func TestHash(t *testing.T) {
+	if Hash("a") == Hash("b") || Hash("a") != Hash("a") || len(Hash("a")) != 64 {
+		t.Errorf("Hash(a) = %q, Hash(b) = %q", Hash("a"), Hash("b"))
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	key, err := Create(ctx, db, "test client", "files:read", "files:write")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM api_keys WHERE key_hash = $1`, Hash(key)) })
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{name: "issued key", key: key},
+		{name: "unknown key", key: key + "x", wantErr: ErrInvalidKey},
+		{name: "empty key", wantErr: ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lookup(ctx, db, tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Lookup error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (got.Label != "test client" || !slices.Equal(got.Scopes, []string{"files:read", "files:write"})) {
+				t.Errorf("Lookup = %+v", got)
+			}
+		})
+	}
+
+	var stored string
+	if err := db.QueryRow(`SELECT key_hash FROM api_keys WHERE key_hash = $1`, Hash(key)).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored == key {
+		t.Error("plaintext key stored")
+	}
+
+	keys, err := List(ctx, db)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	i := slices.IndexFunc(keys, func(k Info) bool { return k.Label == "test client" && !k.Revoked })
+	if i < 0 {
+		t.Fatal("List is missing the issued key")
+	}
+	if keys[i].LastUsedAt == nil {
+		t.Error("LastUsedAt not set after Lookup")
+	}
+
+	for range 2 {
+		if err := Revoke(ctx, db, keys[i].ID); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+	}
+	if _, err := Lookup(ctx, db, key); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Lookup of a revoked key error = %v, want ErrInvalidKey", err)
+	}
+	if err := Revoke(ctx, db, -1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Revoke of an unknown id error = %v, want ErrNotFound", err)
+	}
+}
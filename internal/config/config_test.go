@@ -0,0 +1,138 @@
+package config
+
+import (
+	"io"
+	"log/slog"
+	"net/netip"
+	"testing"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// defaults returns the config loaded from an empty environment
+func defaults(t *testing.T) Config {
+	t.Helper()
+	return LoadConfig(discardLogger, nil)
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	t.Setenv("PORT", "9000")
+	t.Setenv("MAX_UPLOAD_BYTES", "1024")
+	t.Setenv("FETCH_ENABLED", "true")
+	t.Setenv("REQUEST_TIMEOUT", "5s")
+	t.Setenv("CORS_ORIGINS", "https://a.example, ,https://b.example")
+
+	c := defaults(t)
+	if c.ListenAddr != ":9000" {
+		t.Errorf("ListenAddr = %q, want :9000 from PORT", c.ListenAddr)
+	}
+	if c.MaxUploadBytes != 1024 {
+		t.Errorf("MaxUploadBytes = %d, want 1024", c.MaxUploadBytes)
+	}
+	if !c.FetchEnabled {
+		t.Error("FetchEnabled = false, want true")
+	}
+	if c.RequestTimeout.String() != "5s" {
+		t.Errorf("RequestTimeout = %s, want 5s", c.RequestTimeout)
+	}
+	if len(c.CORSOrigins) != 2 || c.CORSOrigins[1] != "https://b.example" {
+		t.Errorf("CORSOrigins = %q", c.CORSOrigins)
+	}
+
+	t.Setenv("LISTEN_ADDR", "127.0.0.1:8000")
+	if c := defaults(t); c.ListenAddr != "127.0.0.1:8000" {
+		t.Errorf("ListenAddr = %q, want LISTEN_ADDR over PORT", c.ListenAddr)
+	}
+}
+
+func TestLoadConfigInvalidValuesFallBack(t *testing.T) {
+	want := defaults(t)
+	t.Setenv("MAX_UPLOAD_BYTES", "lots")
+	t.Setenv("FETCH_ENABLED", "maybe")
+	t.Setenv("REQUEST_TIMEOUT", "soon")
+
+	c := defaults(t)
+	if c.MaxUploadBytes != want.MaxUploadBytes || c.FetchEnabled != want.FetchEnabled || c.RequestTimeout != want.RequestTimeout {
+		t.Errorf("invalid values didn't fall back to the defaults: %d %v %s", c.MaxUploadBytes, c.FetchEnabled, c.RequestTimeout)
+	}
+}
+
+func TestTrustedProxyPrefixes(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		want    []netip.Prefix
+		wantErr bool
+	}{
+		{name: "none", want: []netip.Prefix{}},
+		{name: "bare addresses", proxies: []string{"10.0.0.1", "::1"}, want: []netip.Prefix{netip.MustParsePrefix("10.0.0.1/32"), netip.MustParsePrefix("::1/128")}},
+		{name: "prefix is masked", proxies: []string{"10.1.2.3/8"}, want: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+		{name: "invalid", proxies: []string{"proxy.internal"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Config{TrustedProxies: tt.proxies}.TrustedProxyPrefixes()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("prefixes = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("prefixes = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMimeSizeLimitsMap(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    map[string]int64
+		wantErr bool
+	}{
+		{raw: ""},
+		{raw: `{"image/*": 1024, "video/mp4": 2048}`, want: map[string]int64{"image/*": 1024, "video/mp4": 2048}},
+		{raw: `{"image/*": 0}`, wantErr: true},
+		{raw: `{"image/*": -1}`, wantErr: true},
+		{raw: `image/*=1024`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := Config{MimeSizeLimits: tt.raw}.MimeSizeLimitsMap()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("limits = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("limits[%s] = %d, want %d", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"", ""},
+		{"postgres://app:hunter2@db:5432/filedb?sslmode=disable", "postgres://app:xxxxx@db:5432/filedb"},
+		{"https://hooks.example/x?token=abc", "https://hooks.example/x"},
+		{"postgres://db/filedb", "postgres://db/filedb"},
+		{"://bad", "REDACTED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if got := RedactURL(tt.raw); got != tt.want {
+				t.Errorf("RedactURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
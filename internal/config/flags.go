@@ -0,0 +1,31 @@
+package config
+
+import "flag"
+
+// NewFlagSet defines the command line flags that override their environment
+// variables. Flags left unset keep the environment or default value.
+func NewFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.String("listen-addr", "", "address to listen on, overrides LISTEN_ADDR and PORT")
+	flags.String("database-url", "", "database connection URL, overrides DATABASE_URL. Visible in the process list, prefer the environment for credentials")
+	flags.Int64("max-upload-bytes", 0, "largest accepted upload body, overrides MAX_UPLOAD_BYTES")
+	flags.String("log-level", "", "debug, info, warn or error, overrides LOG_LEVEL")
+	return flags
+}
+
+// applyFlags overrides c with the flags explicitly set on the command line
+func (c *Config) applyFlags(flags *flag.FlagSet) {
+	flags.Visit(func(f *flag.Flag) {
+		v := f.Value.(flag.Getter).Get()
+		switch f.Name {
+		case "listen-addr":
+			c.ListenAddr = v.(string)
+		case "database-url":
+			c.DatabaseURL = v.(string)
+		case "max-upload-bytes":
+			c.MaxUploadBytes = v.(int64)
+		case "log-level":
+			c.LogLevel = v.(string)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package config
+
+import "testing"
+
+func TestFlagsOverrideEnv(t *testing.T) {
+	t.Setenv("LISTEN_ADDR", ":9000")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("MAX_UPLOAD_BYTES", "1024")
+
+	tests := []struct {
+		name   string
+		args   []string
+		addr   string
+		level  string
+		upload int64
+		dbURL  string
+	}{
+		{name: "no flags", addr: ":9000", level: "warn", upload: 1024},
+		{name: "all flags", args: []string{"-listen-addr", ":7000", "-log-level", "debug", "-max-upload-bytes", "2048", "-database-url", "postgres://db/x"}, addr: ":7000", level: "debug", upload: 2048, dbURL: "postgres://db/x"},
+		{name: "empty flag value still wins", args: []string{"-log-level="}, addr: ":9000", level: "", upload: 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := NewFlagSet("test")
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			c := LoadConfig(discardLogger, flags)
+			if c.ListenAddr != tt.addr || c.LogLevel != tt.level || c.MaxUploadBytes != tt.upload {
+				t.Errorf("got addr %q, level %q, upload %d; want %q, %q, %d", c.ListenAddr, c.LogLevel, c.MaxUploadBytes, tt.addr, tt.level, tt.upload)
+			}
+			if tt.dbURL != "" && c.DatabaseURL != tt.dbURL {
+				t.Errorf("DatabaseURL = %q, want %q", c.DatabaseURL, tt.dbURL)
+			}
+		})
+	}
+}
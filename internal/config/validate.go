@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MinSecretLength is the shortest auth secret accepted in production
+const MinSecretLength = 32
+
+// FieldError describes a single invalid config value
+type FieldError struct {
+	Field   string
+	Problem string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Problem
+}
+
+// ValidationError lists every problem found by Validate
+type ValidationError struct {
+	Problems []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the config invariants and reports all violations at once
+// as a *ValidationError.
+func (c Config) Validate() error {
+	var problems []FieldError
+	add := func(field, format string, args ...any) {
+		problems = append(problems, FieldError{Field: field, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Production() && len(c.AuthSecret) < MinSecretLength {
+		add("AUTH_SECRET", "must be at least %d characters in production", MinSecretLength)
+	}
+	if c.SigningSecret != "" && c.Production() && len(c.SigningSecret) < MinSecretLength {
+		add("SIGNING_SECRET", "must be at least %d characters in production", MinSecretLength)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		add("LOG_LEVEL", "unsupported level %q", c.LogLevel)
+	}
+	if !slices.Contains([]string{LogFormatJSON, LogFormatText}, c.LogFormat) {
+		add("LOG_FORMAT", "unsupported format %q", c.LogFormat)
+	}
+	if !slices.Contains([]string{DuplicateAllow, DuplicateReject, DuplicateReplace}, c.OnDuplicate) {
+		add("ON_DUPLICATE", "must be allow, reject or replace, got %q", c.OnDuplicate)
+	}
+	if !slices.Contains([]string{AuthModeStatic, AuthModeAPIKey, AuthModeJWT, AuthModeBasic}, c.AuthMode) {
+		add("AUTH_MODE", "unsupported mode %q", c.AuthMode)
+	}
+	if c.AuthMode == AuthModeBasic {
+		if c.BasicAuthUsername == "" {
+			add("BASIC_AUTH_USERNAME", "required for basic auth")
+		}
+		if c.BasicAuthPassword == "" {
+			add("BASIC_AUTH_PASSWORD", "required for basic auth")
+		} else if c.Production() && len(c.BasicAuthPassword) < MinSecretLength {
+			add("BASIC_AUTH_PASSWORD", "must be at least %d characters in production", MinSecretLength)
+		}
+	}
+	if !slices.Contains([]string{DBDriverPQ, DBDriverPGX}, c.DBDriver) {
+		add("DB_DRIVER", "unsupported driver %q", c.DBDriver)
+	}
+	switch c.StorageBackend {
+	case StorageDB:
+	case StorageFS:
+		if c.StorageDir == "" {
+			add("STORAGE_DIR", "required for the fs storage backend")
+		}
+	case StorageS3:
+		if c.S3Bucket == "" {
+			add("S3_BUCKET", "required for the s3 storage backend")
+		}
+	default:
+		add("STORAGE_BACKEND", "unsupported backend %q", c.StorageBackend)
+	}
+	if c.MaxUploadBytes <= 0 {
+		add("MAX_UPLOAD_BYTES", "must be positive, got %d", c.MaxUploadBytes)
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		add("TLS_CERT_FILE", "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.HTTP3Enabled && !c.TLSEnabled() {
+		add("HTTP3_ENABLED", "HTTP/3 requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	if key, err := c.EncryptionKeyBytes(); err != nil || (key != nil && len(key) != 32) {
+		add("ENCRYPTION_KEY", "must be 32 bytes, base64 encoded")
+	}
+	if _, port, err := net.SplitHostPort(c.ListenAddr); err != nil || port == "" {
+		add("LISTEN_ADDR", "must be host:port or :port, got %q", c.ListenAddr)
+	}
+	if _, err := c.MimeSizeLimitsMap(); err != nil {
+		add("MIME_SIZE_LIMITS", "%v", err)
+	}
+	if c.MaxFilesPerRequest <= 0 {
+		add("MAX_FILES_PER_REQUEST", "must be positive")
+	}
+	if c.MultipartMemoryBytes <= 0 {
+		add("MULTIPART_MEMORY_BYTES", "must be positive")
+	}
+	for _, ext := range c.AllowedExtensions {
+		if strings.Trim(ext, ".") == "" || strings.ContainsAny(ext, `/\`) {
+			add("ALLOWED_EXTENSIONS", "invalid extension %q", ext)
+		}
+	}
+	if c.PresignTTL <= 0 || c.PresignTTL > 7*24*time.Hour {
+		add("PRESIGN_TTL", "must be positive and at most 168h, got %s", c.PresignTTL)
+	}
+	if c.FetchEnabled && c.FetchTimeout <= 0 {
+		add("FETCH_TIMEOUT", "must be positive")
+	}
+	if c.FetchEnabled && c.FetchMaxBytes <= 0 {
+		add("FETCH_MAX_BYTES", "must be positive")
+	}
+	if c.EventsEnabled && c.MaxEventSubscribers <= 0 {
+		add("MAX_EVENT_SUBSCRIBERS", "must be positive")
+	}
+	if c.IdempotencyKeyTTL <= 0 {
+		add("IDEMPOTENCY_KEY_TTL", "must be positive")
+	}
+	if c.DBShedMaxWaitCount > 0 && c.DBShedWindow <= 0 {
+		add("DB_SHED_WINDOW", "must be positive")
+	}
+	if c.DrainTimeout <= 0 {
+		add("DRAIN_TIMEOUT", "must be positive")
+	}
+	if c.MaxHeaderBytes < 0 {
+		add("MAX_HEADER_BYTES", "must not be negative")
+	}
+	if c.MaxHeaderCount < 0 {
+		add("MAX_HEADER_COUNT", "must not be negative")
+	}
+	if c.CleanupTimeout <= 0 {
+		add("CLEANUP_TIMEOUT", "must be positive")
+	}
+	if c.VerifyMismatchStatus != http.StatusOK && c.VerifyMismatchStatus != http.StatusConflict {
+		add("VERIFY_MISMATCH_STATUS", "must be 200 or 409, got %d", c.VerifyMismatchStatus)
+	}
+	if c.H2CEnabled && c.TLSEnabled() {
+		add("H2C_ENABLED", "h2c is cleartext only, TLS already negotiates HTTP/2")
+	}
+	if c.StaleOnError && c.StaleCacheBytes <= 0 {
+		add("STALE_CACHE_BYTES", "must be positive when STALE_ON_ERROR is enabled")
+	}
+	if c.WebhookURL != "" {
+		if u, err := url.Parse(c.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("WEBHOOK_URL", "must be an absolute http(s) URL")
+		}
+	}
+	if c.PanicWebhookURL != "" {
+		if u, err := url.Parse(c.PanicWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("PANIC_WEBHOOK_URL", "must be an absolute http(s) URL")
+		}
+	}
+	if _, err := c.TrustedProxyPrefixes(); err != nil {
+		add("TRUSTED_PROXIES", "%v", err)
+	}
+	for _, origin := range c.CORSOrigins {
+		if !validOrigin(origin) {
+			add("CORS_ORIGINS", "invalid origin %q", origin)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validOrigin accepts "*" or a bare scheme://host[:port] origin
+func validOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.Fragment == ""
+}
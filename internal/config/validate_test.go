@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateDefaults(t *testing.T) {
+	if err := defaults(t).Validate(); err != nil {
+		t.Errorf("defaults are invalid: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	long := strings.Repeat("s", MinSecretLength)
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		fields []string
+	}{
+		{name: "short secret in development", change: func(c *Config) { c.AuthSecret = "dev" }},
+		{name: "short secret in production", change: func(c *Config) { c.Env, c.AuthSecret = EnvProduction, "short" }, fields: []string{"AUTH_SECRET"}},
+		{name: "long secret in production", change: func(c *Config) { c.Env, c.AuthSecret = EnvProduction, long }},
+		{name: "short signing secret in production", change: func(c *Config) { c.Env, c.AuthSecret, c.SigningSecret = EnvProduction, long, "short" }, fields: []string{"SIGNING_SECRET"}},
+		{name: "basic auth without credentials", change: func(c *Config) { c.AuthMode = AuthModeBasic }, fields: []string{"BASIC_AUTH_USERNAME", "BASIC_AUTH_PASSWORD"}},
+		{name: "short basic password in production", change: func(c *Config) {
+			c.Env, c.AuthSecret, c.AuthMode, c.BasicAuthUsername, c.BasicAuthPassword = EnvProduction, long, AuthModeBasic, "admin", "pw"
+		}, fields: []string{"BASIC_AUTH_PASSWORD"}},
+		{name: "unknown auth mode", change: func(c *Config) { c.AuthMode = "oauth" }, fields: []string{"AUTH_MODE"}},
+		{name: "unknown driver", change: func(c *Config) { c.DBDriver = "mysql" }, fields: []string{"DB_DRIVER"}},
+		{name: "fs without dir", change: func(c *Config) { c.StorageBackend, c.StorageDir = StorageFS, "" }, fields: []string{"STORAGE_DIR"}},
+		{name: "s3 without bucket", change: func(c *Config) { c.StorageBackend = StorageS3 }, fields: []string{"S3_BUCKET"}},
+		{name: "unknown backend", change: func(c *Config) { c.StorageBackend = "ftp" }, fields: []string{"STORAGE_BACKEND"}},
+		{name: "zero upload size", change: func(c *Config) { c.MaxUploadBytes = 0 }, fields: []string{"MAX_UPLOAD_BYTES"}},
+		{name: "cert without key", change: func(c *Config) { c.TLSCertFile = "cert.pem" }, fields: []string{"TLS_CERT_FILE"}},
+		{name: "http3 without tls", change: func(c *Config) { c.HTTP3Enabled = true }, fields: []string{"HTTP3_ENABLED"}},
+		{name: "h2c with tls", change: func(c *Config) { c.H2CEnabled, c.TLSCertFile, c.TLSKeyFile = true, "c", "k" }, fields: []string{"H2C_ENABLED"}},
+		{name: "short encryption key", change: func(c *Config) { c.EncryptionKey = "c2hvcnQ=" }, fields: []string{"ENCRYPTION_KEY"}},
+		{name: "encryption key not base64", change: func(c *Config) { c.EncryptionKey = "not base64!" }, fields: []string{"ENCRYPTION_KEY"}},
+		{name: "valid encryption key", change: func(c *Config) { c.EncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" }},
+		{name: "listen addr without port", change: func(c *Config) { c.ListenAddr = "localhost" }, fields: []string{"LISTEN_ADDR"}},
+		{name: "bad log level", change: func(c *Config) { c.LogLevel = "loud" }, fields: []string{"LOG_LEVEL"}},
+		{name: "bad log format", change: func(c *Config) { c.LogFormat = "xml" }, fields: []string{"LOG_FORMAT"}},
+		{name: "bad duplicate policy", change: func(c *Config) { c.OnDuplicate = "rename" }, fields: []string{"ON_DUPLICATE"}},
+		{name: "bad mime limits", change: func(c *Config) { c.MimeSizeLimits = `{"image/*": 0}` }, fields: []string{"MIME_SIZE_LIMITS"}},
+		{name: "bad extension", change: func(c *Config) { c.AllowedExtensions = []string{".pdf", "a/b"} }, fields: []string{"ALLOWED_EXTENSIONS"}},
+		{name: "presign ttl too long", change: func(c *Config) { c.PresignTTL = 8 * 24 * time.Hour }, fields: []string{"PRESIGN_TTL"}},
+		{name: "fetch without limits", change: func(c *Config) { c.FetchEnabled, c.FetchTimeout, c.FetchMaxBytes = true, 0, 0 }, fields: []string{"FETCH_TIMEOUT", "FETCH_MAX_BYTES"}},
+		{name: "negative header limits", change: func(c *Config) { c.MaxHeaderBytes, c.MaxHeaderCount = -1, -1 }, fields: []string{"MAX_HEADER_BYTES", "MAX_HEADER_COUNT"}},
+		{name: "bad verify status", change: func(c *Config) { c.VerifyMismatchStatus = 500 }, fields: []string{"VERIFY_MISMATCH_STATUS"}},
+		{name: "shed waits without window", change: func(c *Config) { c.DBShedMaxWaitCount, c.DBShedWindow = 5, 0 }, fields: []string{"DB_SHED_WINDOW"}},
+		{name: "stale cache without size", change: func(c *Config) { c.StaleOnError, c.StaleCacheBytes = true, 0 }, fields: []string{"STALE_CACHE_BYTES"}},
+		{name: "relative webhook", change: func(c *Config) { c.WebhookURL = "/hook" }, fields: []string{"WEBHOOK_URL"}},
+		{name: "ftp panic webhook", change: func(c *Config) { c.PanicWebhookURL = "ftp://hooks.example" }, fields: []string{"PANIC_WEBHOOK_URL"}},
+		{name: "bad trusted proxy", change: func(c *Config) { c.TrustedProxies = []string{"lb"} }, fields: []string{"TRUSTED_PROXIES"}},
+		{name: "origin with path", change: func(c *Config) { c.CORSOrigins = []string{"https://a.example/app"} }, fields: []string{"CORS_ORIGINS"}},
+		{name: "valid origins", change: func(c *Config) { c.CORSOrigins = []string{"*", "http://localhost:3000"} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaults(t)
+			tt.change(&c)
+			err := c.Validate()
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate = %v, want a *ValidationError", err)
+			}
+			var got []string
+			for _, p := range verr.Problems {
+				got = append(got, p.Field)
+			}
+			if !slices.Equal(got, tt.fields) {
+				t.Errorf("fields = %v, want %v (%v)", got, tt.fields, err)
+			}
+		})
+	}
+}
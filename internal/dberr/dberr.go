@@ -0,0 +1,91 @@
+package dberr
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+// SQLSTATE codes the service reacts to
+const (
+	UniqueViolation  = "23505"
+	TooManyClients   = "53300"
+	AdminShutdown    = "57P01"
+	CannotConnect    = "57P03"
+	ProgramLimit     = "54000" // e.g. a value over the 1GB field limit
+	LockNotAvailable = "55P03" // a NOWAIT lock is held elsewhere
+
+	InvalidCatalogName = "3D000" // the database doesn't exist
+)
+
+// Code returns the SQLSTATE of a Postgres error from either supported
+// driver, or "" when err doesn't come from the server
+func Code(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// IsUnavailable reports whether err means the database is temporarily out
+// of reach: a timed out wait for a connection, a broken connection, or the
+// server refusing or dropping connections. Retrying later can succeed.
+func IsUnavailable(err error) bool {
+	if IsMissingDatabase(err) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
+	}
+	var connErr *pgconn.ConnectError
+	if errors.As(err, &connErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	code := Code(err)
+	return strings.HasPrefix(code, "08") || code == TooManyClients || code == AdminShutdown || code == CannotConnect
+}
+
+// IsMissingDatabase reports whether err means the database named in the
+// connection URL doesn't exist, which retrying won't fix
+func IsMissingDatabase(err error) bool {
+	return Code(err) == InvalidCatalogName
+}
+
+// IsTooLarge reports whether err means a value was too large for the
+// server to store or allocate memory for, which retrying won't fix. Failed
+// allocations come with the generic internal error code, so they're
+// recognized by message.
+func IsTooLarge(err error) bool {
+	if Code(err) == ProgramLimit {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), "invalid memory alloc request size")
+}
+
+// IsLockNotAvailable reports whether err means a row locked with NOWAIT is
+// held by another transaction
+func IsLockNotAvailable(err error) bool {
+	return Code(err) == LockNotAvailable
+}
+
+// IsUniqueViolation reports whether err is a unique constraint violation
+func IsUniqueViolation(err error) bool {
+	return Code(err) == UniqueViolation
+}
@@ -0,0 +1,67 @@
+package dberr
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+func TestClassify(t *testing.T) {
+	pqErr := func(code string) error { return fmt.Errorf("query: %w", &pq.Error{Code: pq.ErrorCode(code)}) }
+	pgxErr := func(code string) error { return fmt.Errorf("query: %w", &pgconn.PgError{Code: code}) }
+
+	tests := []struct {
+		name        string
+		err         error
+		code        string
+		unavailable bool
+		missing     bool
+		tooLarge    bool
+		locked      bool
+		unique      bool
+	}{
+		{name: "nil"},
+		{name: "plain error", err: errors.New("boom")},
+		{name: "pq unique", err: pqErr(UniqueViolation), code: UniqueViolation, unique: true},
+		{name: "pgx unique", err: pgxErr(UniqueViolation), code: UniqueViolation, unique: true},
+		{name: "too many clients", err: pqErr(TooManyClients), code: TooManyClients, unavailable: true},
+		{name: "admin shutdown", err: pgxErr(AdminShutdown), code: AdminShutdown, unavailable: true},
+		{name: "connection exception", err: pqErr("08006"), code: "08006", unavailable: true},
+		{name: "missing database", err: pqErr(InvalidCatalogName), code: InvalidCatalogName, missing: true},
+		{name: "program limit", err: pgxErr(ProgramLimit), code: ProgramLimit, tooLarge: true},
+		{name: "failed allocation", err: errors.New("pq: invalid memory alloc request size 1073741824"), tooLarge: true},
+		{name: "nowait lock", err: pqErr(LockNotAvailable), code: LockNotAvailable, locked: true},
+		{name: "pool wait timeout", err: fmt.Errorf("get conn: %w", context.DeadlineExceeded), unavailable: true},
+		{name: "bad connection", err: driver.ErrBadConn, unavailable: true},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("refused")}, unavailable: true},
+		{name: "canceled", err: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Code(tt.err); got != tt.code {
+				t.Errorf("Code = %q, want %q", got, tt.code)
+			}
+			if got := IsUnavailable(tt.err); got != tt.unavailable {
+				t.Errorf("IsUnavailable = %v, want %v", got, tt.unavailable)
+			}
+			if got := IsMissingDatabase(tt.err); got != tt.missing {
+				t.Errorf("IsMissingDatabase = %v, want %v", got, tt.missing)
+			}
+			if got := IsTooLarge(tt.err); got != tt.tooLarge {
+				t.Errorf("IsTooLarge = %v, want %v", got, tt.tooLarge)
+			}
+			if got := IsLockNotAvailable(tt.err); got != tt.locked {
+				t.Errorf("IsLockNotAvailable = %v, want %v", got, tt.locked)
+			}
+			if got := IsUniqueViolation(tt.err); got != tt.unique {
+				t.Errorf("IsUniqueViolation = %v, want %v", got, tt.unique)
+			}
+		})
+	}
+}
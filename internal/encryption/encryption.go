@@ -0,0 +1,59 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the AES-256 key length in bytes
+const KeySize = 32
+
+// ErrDecrypt is returned for content that wasn't sealed with this key or
+// has been tampered with
+var ErrDecrypt = errors.New("decrypt content: authentication failed")
+
+// Cipher seals content with AES-256-GCM, prepending the random nonce
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// New creates a cipher for a KeySize byte key
+func New(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts plain, the result starts with the nonce
+func (c *Cipher) Seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plain)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+// Open decrypts content produced by Seal
+func (c *Cipher) Open(sealed []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(sealed) < n+c.aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	plain, err := c.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
@@ -0,0 +1,100 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func testCipher(t *testing.T, fill byte) *Cipher {
+	t.Helper()
+	c, err := New(bytes.Repeat([]byte{fill}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{name: "aes-256", size: KeySize},
+		{name: "empty", size: 0, wantErr: true},
+		{name: "aes-128 rejected", size: 16, wantErr: true},
+		{name: "too long", size: KeySize + 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(make([]byte, tt.size))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New(%d bytes) error = %v, wantErr %v", tt.size, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	c := testCipher(t, 1)
+	for _, plain := range [][]byte{nil, []byte("x"), bytes.Repeat([]byte("content"), 1000)} {
+		sealed, err := c.Seal(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plain) > 0 && bytes.Contains(sealed, plain) {
+			t.Error("sealed content contains the plaintext")
+		}
+		got, err := c.Open(sealed)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("Open = %q, want %q", got, plain)
+		}
+	}
+}
+
+func TestSealUsesFreshNonces(t *testing.T) {
+	c := testCipher(t, 1)
+	a, _ := c.Seal([]byte("same"))
+	b, _ := c.Seal([]byte("same"))
+	if bytes.Equal(a, b) {
+		t.Error("sealing the same content twice gave the same output")
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	c := testCipher(t, 1)
+	sealed, err := c.Seal([]byte("secret content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip := func(i int) []byte {
+		b := bytes.Clone(sealed)
+		b[i] ^= 1
+		return b
+	}
+
+	tests := []struct {
+		name   string
+		cipher *Cipher
+		input  []byte
+	}{
+		{name: "other key", cipher: testCipher(t, 2), input: sealed},
+		{name: "tampered nonce", cipher: c, input: flip(0)},
+		{name: "tampered ciphertext", cipher: c, input: flip(len(sealed) / 2)},
+		{name: "tampered tag", cipher: c, input: flip(len(sealed) - 1)},
+		{name: "truncated", cipher: c, input: sealed[:len(sealed)-1]},
+		{name: "shorter than the nonce", cipher: c, input: sealed[:4]},
+		{name: "empty", cipher: c},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cipher.Open(tt.input); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("Open error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
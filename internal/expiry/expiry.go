@@ -0,0 +1,124 @@
+package expiry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"inv/internal/logging"
+	"inv/internal/storage"
+)
+
+// batchSize bounds the rows deleted by a single statement
+const batchSize = 100
+
+// sessionTTL is how long an idle resumable upload is kept
+const sessionTTL = 24 * time.Hour
+
+// Sweeper periodically deletes files past their expires_at along with their
+// stored content, and resumable uploads idle for longer than sessionTTL.
+type Sweeper struct {
+	db       *sql.DB
+	store    storage.Storage
+	logger   logging.Logger
+	interval time.Duration
+}
+
+// New creates a sweeper
+func New(db *sql.DB, store storage.Storage, logger logging.Logger, interval time.Duration) *Sweeper {
+	return &Sweeper{db: db, store: store, logger: logger, interval: interval}
+}
+
+// Run sweeps until ctx is canceled
+func (s *Sweeper) Run(ctx context.Context) {
+	for {
+		n, err := s.Sweep(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error(ctx, "expiry sweep failed", slog.String("error", err.Error()))
+		}
+		if n > 0 {
+			s.logger.Info(ctx, "expired files deleted", slog.Int("count", n))
+		}
+		if err := s.dropSessions(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error(ctx, "dropping idle uploads failed", slog.String("error", err.Error()))
+		}
+		if err := s.dropIdempotencyKeys(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error(ctx, "dropping expired idempotency keys failed", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// Sweep deletes every expired file and returns how many were removed
+func (s *Sweeper) Sweep(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		keys, err := s.deleteBatch(ctx)
+		total += len(keys)
+		if err != nil {
+			return total, err
+		}
+
+		// The rows are gone, content left behind is only wasted space
+		for _, key := range keys {
+			if err := s.store.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				s.logger.Warn(ctx, "failed to delete expired content",
+					slog.String("key", key),
+					slog.String("error", err.Error()),
+				)
+			}
+		}
+		if len(keys) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// dropSessions deletes resumable uploads nobody has touched for sessionTTL
+func (s *Sweeper) dropSessions(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+        DELETE FROM upload_sessions
+        WHERE updated_at < CURRENT_TIMESTAMP - $1::float8 * INTERVAL '1 second'`,
+		sessionTTL.Seconds())
+	return err
+}
+
+// dropIdempotencyKeys deletes idempotency keys past their expires_at
+func (s *Sweeper) dropIdempotencyKeys(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP`)
+	return err
+}
+
+// deleteBatch deletes up to batchSize expired rows and returns their storage keys
+func (s *Sweeper) deleteBatch(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        DELETE FROM files
+        WHERE id IN (
+            SELECT id FROM files
+            WHERE expires_at <= CURRENT_TIMESTAMP
+            LIMIT $1
+        )
+        RETURNING storage_key`, batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("delete expired files: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return keys, fmt.Errorf("scan expired file: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,75 @@
+package expiry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"inv/internal/logging"
+	"inv/internal/storage"
+	"inv/internal/testdb"
+)
+
+func TestSweep(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	store, err := storage.NewFS(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// insert stores a file expiring after ttl, or never when ttl is zero
+	insert := func(ttl time.Duration) (int64, string) {
+		key, _ := storage.NewKey()
+		var expires any
+		if ttl != 0 {
+			expires = time.Now().Add(ttl)
+		}
+		var id int64
+		err := db.QueryRow(`
+            INSERT INTO files (filename, mime_type, size, storage_key, expires_at)
+            VALUES ('expiry.txt', 'text/plain', 1, $1, $2) RETURNING id`, key, expires).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Exec(`DELETE FROM files WHERE id = $1`, id) })
+		if err := store.Put(ctx, key, strings.NewReader("x")); err != nil {
+			t.Fatal(err)
+		}
+		return id, key
+	}
+
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		deleted bool
+	}{
+		{name: "expired", ttl: -time.Minute, deleted: true},
+		{name: "not yet expired", ttl: time.Hour},
+		{name: "no expiry"},
+	}
+	ids := make([]int64, len(tests))
+	keys := make([]string, len(tests))
+	for i, tt := range tests {
+		ids[i], keys[i] = insert(tt.ttl)
+	}
+
+	if _, err := New(db, store, logging.Discard, time.Minute).Sweep(ctx); err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exists bool
+			db.QueryRow(`SELECT EXISTS (SELECT 1 FROM files WHERE id = $1)`, ids[i]).Scan(&exists)
+			if exists == tt.deleted {
+				t.Errorf("row exists = %v, want %v", exists, !tt.deleted)
+			}
+			_, err := store.Get(ctx, keys[i])
+			if gone := errors.Is(err, storage.ErrNotFound); gone != tt.deleted {
+				t.Errorf("content deleted = %v, want %v", gone, tt.deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"inv/internal/storage"
+)
+
+// maxArchiveFiles caps the number of ids in one archive request
+const maxArchiveFiles = 1000
+
+// archiveManifest is the name of the entry listing what the archive holds
+const archiveManifest = "manifest.json"
+
+// archiveEntry describes a file included in an archive
+type archiveEntry struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// ArchiveFiles streams a ZIP archive of the files listed in ?ids=1,2,3.
+// Ids that don't exist are skipped and reported in the manifest entry.
+func (h *Handlers) ArchiveFiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, err := parseIDs(r.URL.Query().Get("ids"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "files.zip"}))
+
+		// The status is sent with the first entry, so failures past this
+		// point can only cut the archive short
+		zw := zip.NewWriter(w)
+		names := map[string]bool{archiveManifest: true}
+		manifest := struct {
+			Files   []archiveEntry `json:"files"`
+			Missing []int64        `json:"missing"`
+		}{Files: []archiveEntry{}, Missing: []int64{}}
+
+		for _, id := range ids {
+			name, err := h.archiveFile(r, zw, id, names)
+			if errors.Is(err, sql.ErrNoRows) || errors.Is(err, storage.ErrNotFound) {
+				manifest.Missing = append(manifest.Missing, id)
+				continue
+			}
+			if err != nil {
+				h.log(r.Context()).Error(r.Context(), "Failed to add file to archive",
+					slog.Int64("id", id),
+					slog.String("error", err.Error()),
+				)
+				return
+			}
+			manifest.Files = append(manifest.Files, archiveEntry{ID: id, Name: name})
+		}
+
+		mw, err := zw.Create(archiveManifest)
+		if err == nil {
+			enc := json.NewEncoder(mw)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(manifest)
+		}
+		if err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to finish archive", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// archiveFile writes the decoded content of file id into zw under a name not
+// yet in names and returns that name
+func (h *Handlers) archiveFile(r *http.Request, zw *zip.Writer, id int64, names map[string]bool) (string, error) {
+	f, err := h.lookupFile(r.Context(), id)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := h.openPlain(r.Context(), f)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	name := uniqueName(archiveName(f.filename, id), names)
+	fw, err := zw.Create(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(fw, body); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// archiveName strips directories from an uploaded filename so entries can't
+// escape the extraction directory
+func archiveName(filename string, id int64) string {
+	name := path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	if name == "." || name == "/" || name == ".." {
+		return "file-" + strconv.FormatInt(id, 10)
+	}
+	return name
+}
+
+// uniqueName suffixes name with a counter until it isn't in names, then records it
+func uniqueName(name string, names map[string]bool) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; names[name]; i++ {
+		name = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	names[name] = true
+	return name
+}
+
+// parseIDs parses a comma separated list of file ids, dropping repeats
+func parseIDs(raw string) ([]int64, error) {
+	if raw == "" {
+		return nil, errors.New("ids is required")
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxArchiveFiles {
+		return nil, fmt.Errorf("at most %d ids are allowed", maxArchiveFiles)
+	}
+	seen := make(map[int64]bool, len(parts))
+	ids := make([]int64, 0, len(parts))
+	for _, p := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file id %q", p)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../etc/passwd", "passwd"},
+		{`..\..\boot.ini`, "boot.ini"},
+		{"/abs/path.txt", "path.txt"},
+		{"..", "file-7"},
+		{"/", "file-7"},
+		{"", "file-7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := archiveName(tt.filename, 7); got != tt.want {
+				t.Errorf("archiveName(%q) = %q, want %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	names := map[string]bool{}
+	var got []string
+	for _, n := range []string{"a.txt", "a.txt", "a.txt", "b", "b", "a (1).txt"} {
+		got = append(got, uniqueName(n, names))
+	}
+	want := []string{"a.txt", "a (1).txt", "a (2).txt", "b", "b (1)", "a (1) (1).txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("names = %q, want %q", got, want)
+	}
+}
+
+func TestParseIDs(t *testing.T) {
+	tooMany := strings.Repeat("1,", maxArchiveFiles) + "1"
+	tests := []struct {
+		raw     string
+		want    []int64
+		wantErr bool
+	}{
+		{raw: "1", want: []int64{1}},
+		{raw: "3, 1,3,2", want: []int64{3, 1, 2}},
+		{raw: "", wantErr: true},
+		{raw: "1,,2", wantErr: true},
+		{raw: "1,x", wantErr: true},
+		{raw: tooMany, wantErr: true},
+		{raw: strings.TrimSuffix(strings.Repeat("1,", maxArchiveFiles), ","), want: []int64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(len(tt.raw))+":"+tt.raw[:min(len(tt.raw), 20)], func(t *testing.T) {
+			got, err := parseIDs(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"inv/internal/middlewares"
+)
+
+// Audited actions
+const (
+	AuditUpload   = "upload"
+	AuditDownload = "download"
+	AuditDelete   = "delete"
+	AuditRestore  = "restore"
+)
+
+// auditEntry is a row of the append-only audit_log table
+type auditEntry struct {
+	ID         int64     `json:"id"`
+	Action     string    `json:"action"`
+	FileID     int64     `json:"file_id"`
+	Actor      string    `json:"actor"`
+	RemoteAddr string    `json:"remote_addr"`
+	At         time.Time `json:"at"`
+}
+
+// newAuditEntry describes action on file id by the caller of r
+func (h *Handlers) newAuditEntry(r *http.Request, action string, id int64) auditEntry {
+	actor, _ := middlewares.PrincipalFrom(r.Context())
+	return auditEntry{
+		Action:     action,
+		FileID:     id,
+		Actor:      actor.Subject,
+		RemoteAddr: h.proxies.ClientIP(r),
+	}
+}
+
+// audit records e. It's best-effort, failures are logged and never fail the
+// audited operation.
+func (h *Handlers) audit(ctx context.Context, e auditEntry) {
+	_, err := h.auditStmt.ExecContext(context.WithoutCancel(ctx), e.Action, e.FileID, e.Actor, e.RemoteAddr)
+	if err != nil {
+		h.log(ctx).Error(ctx, "Failed to write audit log",
+			slog.String("action", e.Action),
+			slog.Int64("file_id", e.FileID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// ListAudit returns audit log entries newest first as a JSON array,
+// paginated with ?limit= and ?offset=. ?file_id= keeps the entries of one file.
+func (h *Handlers) ListAudit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		limit, offset, err := parsePage(query.Get("limit"), query.Get("offset"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var fileID *int64
+		if raw := query.Get("file_id"); raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid file_id", http.StatusBadRequest)
+				return
+			}
+			fileID = &id
+		}
+
+		rows, err := h.db.QueryContext(r.Context(), `
+            SELECT id, action, COALESCE(file_id, 0), actor, remote_addr, at
+            FROM audit_log
+            WHERE $1::bigint IS NULL OR file_id = $1
+            ORDER BY id DESC
+            LIMIT $2 OFFSET $3`, fileID, limit, offset)
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to list audit log", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to list audit log")
+			return
+		}
+		defer rows.Close()
+
+		entries := []auditEntry{}
+		for rows.Next() {
+			var e auditEntry
+			if err := rows.Scan(&e.ID, &e.Action, &e.FileID, &e.Actor, &e.RemoteAddr, &e.At); err != nil {
+				h.log(r.Context()).Error(r.Context(), "Failed to read audit log", slog.String("error", err.Error()))
+				writeDBError(w, err, "Failed to list audit log")
+				return
+			}
+			entries = append(entries, e)
+		}
+		if err := rows.Err(); err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to read audit log", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to list audit log")
+			return
+		}
+		encodeResponse(w, r, http.StatusOK, entries)
+	}
+}
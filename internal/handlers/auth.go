@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"inv/internal/middlewares"
+)
+
+// VerifyAuth reports the authenticated principal. Invalid credentials never
+// reach it, the auth middleware answers those with 401.
+func (h *Handlers) VerifyAuth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := middlewares.PrincipalFrom(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		scopes := p.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"subject": p.Subject, "scopes": scopes})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"inv/internal/apikeys"
+	"inv/internal/logging"
+	"inv/internal/middlewares"
+)
+
+func TestVerifyAuth(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	static := middlewares.Auth(logging.Discard, secret)
+	basic := middlewares.BasicAuth(logging.Discard, "alice", "s3cret")
+	apiKey := middlewares.APIKeyAuth(logging.Discard, func(_ context.Context, key string) (middlewares.Principal, error) {
+		if key != "good-key" {
+			return middlewares.Principal{}, apikeys.ErrInvalidKey
+		}
+		return middlewares.Principal{Subject: "ci", Scopes: []string{"files:read"}}, nil
+	})
+	none := func(next http.Handler) http.Handler { return next }
+
+	basicHeader := func(user, pass string) string {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.SetBasicAuth(user, pass)
+		return r.Header.Get("Authorization")
+	}
+
+	tests := []struct {
+		name    string
+		auth    func(http.Handler) http.Handler
+		header  string
+		value   string
+		status  int
+		subject string
+		scopes  []string
+	}{
+		{name: "static secret", auth: static, header: "Authorization", value: secret, status: http.StatusOK, subject: "static", scopes: []string{middlewares.ScopeAdmin}},
+		{name: "wrong static secret", auth: static, header: "Authorization", value: "wrong", status: http.StatusUnauthorized},
+		{name: "missing static secret", auth: static, status: http.StatusUnauthorized},
+		{name: "basic credentials", auth: basic, header: "Authorization", value: basicHeader("alice", "s3cret"), status: http.StatusOK, subject: "alice", scopes: []string{middlewares.ScopeAdmin}},
+		{name: "wrong basic password", auth: basic, header: "Authorization", value: basicHeader("alice", "nope"), status: http.StatusUnauthorized},
+		{name: "api key", auth: apiKey, header: "X-API-Key", value: "good-key", status: http.StatusOK, subject: "ci", scopes: []string{"files:read"}},
+		{name: "unknown api key", auth: apiKey, header: "X-API-Key", value: "bad-key", status: http.StatusUnauthorized},
+		{name: "no auth middleware", auth: none, status: http.StatusUnauthorized},
+	}
+	h := &Handlers{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/auth/verify", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			tt.auth(h.VerifyAuth()).ServeHTTP(rec, r)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var got struct {
+				Subject string   `json:"subject"`
+				Scopes  []string `json:"scopes"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Subject != tt.subject || !slices.Equal(got.Scopes, tt.scopes) {
+				t.Errorf("principal = %q %v, want %q %v", got.Subject, got.Scopes, tt.subject, tt.scopes)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"inv/internal/middlewares"
+	"inv/internal/storage"
+)
+
+// insertBatchSize bounds the rows of one multi-row INSERT, keeping it well
+// under the 65535 bind parameters Postgres accepts
+const insertBatchSize = 500
+
+// batchResult describes one file of a batch upload
+type batchResult struct {
+	ID       int64  `json:"id"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+	Deduped  bool   `json:"deduped"`
+
+	// Replaced is set when the file overwrote one of the same name under
+	// ON_DUPLICATE=replace
+	Replaced bool `json:"replaced"`
+}
+
+// AddFiles stores every multipart "file" field of the request in a single
+// multi-row INSERT and returns the results as a JSON array in request order.
+// "metadata" and "ttl_seconds" apply to all files. Names already taken are
+// handled by the ON_DUPLICATE policy, see storeNamedBatch.
+func (h *Handlers) AddFiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		us, release, ok := h.readUploads(w, r)
+		if !ok {
+			return
+		}
+		defer release()
+
+		metadata, err := parseMetadata(r.FormValue("metadata"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ttl, err := parseTTL(r.FormValue("ttl_seconds"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		owner, _ := middlewares.PrincipalFrom(r.Context())
+		files := make([]newFile, len(us))
+		for i, u := range us {
+			files[i] = newFile{
+				Filename: u.Filename,
+				MimeType: u.MimeType,
+				Content:  u.Content,
+				Owner:    owner.Subject,
+				Metadata: metadata,
+				TTL:      ttl,
+			}
+		}
+
+		stored, replaced, err := h.storeNamedBatch(r.Context(), files)
+		if taken := (*nameTakenError)(nil); errors.As(err, &taken) {
+			writeError(w, http.StatusConflict, taken.Error())
+			return
+		}
+		if errors.Is(err, errRepeatedName) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to save files", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to save files to database")
+			return
+		}
+
+		results := make([]batchResult, len(stored))
+		for i, sf := range stored {
+			h.audit(r.Context(), h.newAuditEntry(r, AuditUpload, sf.ID))
+			results[i] = batchResult{ID: sf.ID, Filename: files[i].Filename, Size: sf.Size, Checksum: sf.Checksum, Deduped: sf.Deduped, Replaced: replaced[i]}
+		}
+		writeJSON(w, http.StatusCreated, results)
+	}
+}
+
+// pendingFile is a file of a batch between encoding and storing its content
+type pendingFile struct {
+	enc      encoded
+	key      string
+	inserted bool
+}
+
+// storeFiles is storeFile for many files with one INSERT round trip per
+// insertBatchSize files. Files that hit the dedup index are resolved to the
+// existing file afterwards, including duplicates within the batch. The rows
+// are committed together once all content is stored, if any content fails
+// to store none of them are.
+func (h *Handlers) storeFiles(ctx context.Context, files []newFile) ([]storedFile, error) {
+	stored := make([]storedFile, len(files))
+	pending := make([]pendingFile, len(files))
+	for i, f := range files {
+		enc, err := h.encodeContent(f.Content)
+		if err != nil {
+			return nil, err
+		}
+		key, err := storage.NewKey()
+		if err != nil {
+			return nil, fmt.Errorf("generate storage key: %w", err)
+		}
+		pending[i] = pendingFile{enc: enc, key: key}
+		stored[i] = storedFile{Size: int64(len(f.Content)), Checksum: checksum(f.Content)}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin insert: %w", err)
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(files); start += insertBatchSize {
+		end := min(start+insertBatchSize, len(files))
+		if err := h.insertBatch(ctx, tx, files[start:end], stored[start:end], pending[start:end]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range files {
+		if !pending[i].inserted {
+			dup, ok, err := h.findDuplicateTx(ctx, tx, files[i].Owner, stored[i].Checksum)
+			if err == nil && !ok {
+				err = errors.New("duplicate vanished after conflict")
+			}
+			if err != nil {
+				tx.Rollback()
+				h.discardContent(ctx, pending[:i])
+				return nil, err
+			}
+			stored[i] = dup
+			continue
+		}
+		if err := h.putContent(ctx, tx, pending[i].key, bytes.NewReader(pending[i].enc.data)); err != nil {
+			tx.Rollback()
+			h.discardContent(ctx, pending[:i+1])
+			return nil, fmt.Errorf("store content: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		h.discardContent(ctx, pending)
+		return nil, fmt.Errorf("commit files: %w", err)
+	}
+
+	for i, f := range files {
+		if pending[i].inserted {
+			h.publish(FileEvent{
+				Action:   ActionUploaded,
+				ID:       stored[i].ID,
+				Filename: f.Filename,
+				Size:     stored[i].Size,
+				Checksum: stored[i].Checksum,
+				At:       stored[i].CreatedAt,
+			})
+		}
+	}
+	return stored, nil
+}
+
+// insertBatch inserts the rows for files in tx in one statement and fills in
+// the ids of stored. Rows skipped by the dedup index stay not inserted.
+func (h *Handlers) insertBatch(ctx context.Context, tx *sql.Tx, files []newFile, stored []storedFile, pending []pendingFile) error {
+	const cols = 11
+	var (
+		values = make([]string, len(files))
+		args   = make([]any, 0, len(files)*cols)
+		byKey  = make(map[string]int, len(files))
+	)
+	for i, f := range files {
+		var dedupKey, ttl any
+		if h.cfg.DedupUploads && f.TTL == 0 {
+			dedupKey = stored[i].Checksum
+		}
+		if f.TTL > 0 {
+			ttl = f.TTL
+		}
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d::jsonb, $%d, $%d, $%d, CURRENT_TIMESTAMP + $%d::float8 * INTERVAL '1 second', $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11)
+		args = append(args, f.Filename, f.MimeType, stored[i].Size, stored[i].Checksum, f.Owner, f.Metadata,
+			pending[i].enc.compressed, pending[i].key, dedupKey, ttl, pending[i].enc.encrypted)
+		byKey[pending[i].key] = i
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+        INSERT INTO files (filename, mime_type, size, checksum, owner_id, metadata, compressed, storage_key, dedup_key, expires_at, encrypted)
+        VALUES `+strings.Join(values, ", ")+`
+        ON CONFLICT (owner_id, dedup_key) WHERE deleted_at IS NULL DO NOTHING
+        RETURNING id, created_at, storage_key`, args...)
+	if err != nil {
+		return fmt.Errorf("insert files: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id        int64
+			createdAt time.Time
+			key       string
+		)
+		if err := rows.Scan(&id, &createdAt, &key); err != nil {
+			return fmt.Errorf("insert files: %w", err)
+		}
+		i := byKey[key]
+		stored[i].ID, stored[i].CreatedAt = id, createdAt
+		pending[i].inserted = true
+	}
+	return rows.Err()
+}
+
+// discardContent removes the content stored for the inserted files of
+// pending after their rows were rolled back
+func (h *Handlers) discardContent(ctx context.Context, pending []pendingFile) {
+	for _, p := range pending {
+		if p.inserted {
+			h.deleteContent(ctx, p.key)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"inv/internal/config"
+	"inv/internal/testdb"
+)
+
+// BenchmarkStoreFiles compares storing 100 small files with the batched
+// INSERT against one storeFile call per file
+func BenchmarkStoreFiles(b *testing.B) {
+	db := testdb.Open(b)
+	h := newTestHandlers(b, db, func(c *config.Config) { c.DedupUploads = false })
+	owner := testOwner(b, db)
+
+	files := make([]newFile, 100)
+	for i := range files {
+		files[i] = newFile{
+			Filename: fmt.Sprintf("small-%d.txt", i),
+			MimeType: "text/plain",
+			Content:  fmt.Appendf(nil, "small file number %d", i),
+			Owner:    owner,
+		}
+	}
+	ctx := context.Background()
+
+	b.Run("batch", func(b *testing.B) {
+		for range b.N {
+			if _, err := h.storeFiles(ctx, files); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("one by one", func(b *testing.B) {
+		for range b.N {
+			for _, f := range files {
+				if _, err := h.storeFile(ctx, f); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// subscriberBuffer is how many events a slow subscriber may fall behind
+	// before further events are dropped for it
+	subscriberBuffer = 64
+
+	// keepAliveInterval spaces SSE comments that keep idle connections from
+	// being closed by proxies
+	keepAliveInterval = 30 * time.Second
+)
+
+// broker fans file events out to the GET /events subscribers. Publishing
+// never blocks, subscribers that can't keep up miss events.
+type broker struct {
+	mu      sync.Mutex
+	subs    map[chan FileEvent]struct{}
+	maxSubs int
+	closed  bool
+}
+
+func newBroker(maxSubs int) *broker {
+	return &broker{subs: make(map[chan FileEvent]struct{}), maxSubs: maxSubs}
+}
+
+// subscribe registers a new subscriber, false when there are maxSubs already
+func (b *broker) subscribe() (chan FileEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || len(b.subs) >= b.maxSubs {
+		return nil, false
+	}
+	ch := make(chan FileEvent, subscriberBuffer)
+	b.subs[ch] = struct{}{}
+	return ch, true
+}
+
+func (b *broker) unsubscribe(ch chan FileEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, ch)
+}
+
+// close ends every subscription by closing its channel, later subscribers
+// are turned away
+func (b *broker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		close(ch)
+		delete(b.subs, ch)
+	}
+}
+
+func (b *broker) publish(e FileEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// CloseEvents ends the GET /events streams. They never finish on their own,
+// so call it when shutdown starts or draining waits out its whole timeout.
+func (h *Handlers) CloseEvents() {
+	if h.events != nil {
+		h.events.close()
+	}
+}
+
+// streamEvent is the JSON data of an SSE event
+type streamEvent struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Events streams file uploads and deletions as Server-Sent Events until the
+// client disconnects. 404 unless EVENTS_ENABLED is set.
+func (h *Handlers) Events() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.events == nil {
+			http.NotFound(w, r)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		ch, ok := h.events.subscribe()
+		if !ok {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Too many event subscribers, retry later", http.StatusServiceUnavailable)
+			return
+		}
+		defer h.events.unsubscribe(ch)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(streamEvent{ID: e.ID, Action: e.Action, Timestamp: e.At})
+				if err != nil {
+					h.log(r.Context()).Error(r.Context(), "Failed to encode event", slog.String("error", err.Error()))
+					return
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Action, data)
+			}
+			flusher.Flush()
+		}
+	}
+}
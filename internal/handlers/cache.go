@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+)
+
+// cachedFile is a previously served file, kept to answer reads while the
+// database is unreachable
+type cachedFile struct {
+	id       int64
+	owner    string
+	filename string
+	mimeType string
+	metadata rawJSON
+	content  []byte
+}
+
+// staleCache is a byte-bounded LRU of served files
+type staleCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // front is most recently used
+	entries  map[int64]*list.Element
+}
+
+func newStaleCache(maxBytes int64) *staleCache {
+	return &staleCache{maxBytes: maxBytes, order: list.New(), entries: make(map[int64]*list.Element)}
+}
+
+// maxEntry is the largest file worth caching, bigger ones would evict too much
+func (c *staleCache) maxEntry() int64 {
+	return c.maxBytes / 4
+}
+
+func (c *staleCache) get(id int64) (*cachedFile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cachedFile), true
+}
+
+func (c *staleCache) put(f *cachedFile) {
+	if int64(len(f.content)) > c.maxEntry() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(f.id)
+	c.entries[f.id] = c.order.PushFront(f)
+	c.size += int64(len(f.content))
+	for c.size > c.maxBytes {
+		c.removeLocked(c.order.Back().Value.(*cachedFile).id)
+	}
+}
+
+func (c *staleCache) remove(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(id)
+}
+
+func (c *staleCache) removeLocked(id int64) {
+	el, ok := c.entries[id]
+	if !ok {
+		return
+	}
+	c.order.Remove(el)
+	delete(c.entries, id)
+	c.size -= int64(len(el.Value.(*cachedFile).content))
+}
+
+// captureWriter buffers up to limit bytes written through it and records
+// whether more were written
+type captureWriter struct {
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	if !c.overflow {
+		if int64(c.buf.Len()+len(p)) > c.limit {
+			c.overflow = true
+			c.buf = bytes.Buffer{}
+		} else {
+			c.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"testing"
+)
+
+func cached(id int64, size int) *cachedFile {
+	return &cachedFile{id: id, content: bytes.Repeat([]byte("x"), size)}
+}
+
+func TestStaleCache(t *testing.T) {
+	c := newStaleCache(100)
+	c.put(cached(1, 20))
+	c.put(cached(2, 20))
+	c.put(cached(3, 20))
+	c.put(cached(4, 20))
+
+	// 1 is used, so 2 is the least recently used when 5 needs room
+	if _, ok := c.get(1); !ok {
+		t.Fatal("get(1) missed")
+	}
+	c.put(cached(5, 25))
+
+	tests := []struct {
+		id     int64
+		cached bool
+	}{
+		{1, true},
+		{2, false},
+		{3, true},
+		{4, true},
+		{5, true},
+	}
+	for _, tt := range tests {
+		if _, ok := c.get(tt.id); ok != tt.cached {
+			t.Errorf("get(%d) found = %v, want %v", tt.id, ok, tt.cached)
+		}
+	}
+	if c.size > c.maxBytes {
+		t.Errorf("size = %d, over %d", c.size, c.maxBytes)
+	}
+}
+
+func TestStaleCacheSkipsLargeFiles(t *testing.T) {
+	c := newStaleCache(100)
+	c.put(cached(1, 26))
+	if _, ok := c.get(1); ok {
+		t.Error("cached a file over a quarter of the cache")
+	}
+}
+
+func TestStaleCacheReplaceAndRemove(t *testing.T) {
+	c := newStaleCache(100)
+	c.put(cached(1, 10))
+	c.put(cached(1, 20))
+	if c.size != 20 {
+		t.Errorf("size = %d after replacing an entry, want 20", c.size)
+	}
+	c.remove(1)
+	c.remove(1)
+	if _, ok := c.get(1); ok || c.size != 0 {
+		t.Errorf("entry still cached after remove, size %d", c.size)
+	}
+}
+
+func TestCaptureWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		overflow bool
+		want     string
+	}{
+		{name: "within limit", writes: []string{"abc", "de"}, want: "abcde"},
+		{name: "at limit", writes: []string{"abcdefghij"}, want: "abcdefghij"},
+		{name: "over limit", writes: []string{"abcdef", "ghijk", "l"}, overflow: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &captureWriter{limit: 10}
+			for _, w := range tt.writes {
+				if n, err := c.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write = %d, %v", n, err)
+				}
+			}
+			if c.overflow != tt.overflow || c.buf.String() != tt.want {
+				t.Errorf("overflow %v buf %q, want %v %q", c.overflow, c.buf.String(), tt.overflow, tt.want)
+			}
+		})
+	}
+}
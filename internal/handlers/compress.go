@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// gzipBytes compresses content, reporting false when that doesn't save space
+func gzipBytes(content []byte) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return nil, false, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, false, err
+	}
+	if buf.Len() >= len(content) {
+		return content, false, nil
+	}
+	return buf.Bytes(), true, nil
+}
+
+// acceptsGzip reports whether the client accepts a gzip Content-Encoding
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		return strings.ReplaceAll(params, " ", "") != "q=0"
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+func TestGzipBytes(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    []byte
+		compressed bool
+	}{
+		{name: "repetitive", content: bytes.Repeat([]byte("abc"), 1000), compressed: true},
+		{name: "tiny", content: []byte("x")},
+		{name: "empty", content: []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, compressed, err := gzipBytes(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compressed != tt.compressed {
+				t.Fatalf("compressed = %v, want %v", compressed, tt.compressed)
+			}
+			if !compressed {
+				if !bytes.Equal(got, tt.content) {
+					t.Error("uncompressed result differs from the content")
+				}
+				return
+			}
+			if len(got) >= len(tt.content) {
+				t.Errorf("compressed to %d bytes from %d", len(got), len(tt.content))
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(got))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain, err := io.ReadAll(zr)
+			if err != nil || !bytes.Equal(plain, tt.content) {
+				t.Errorf("round trip failed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// idleWriter pushes the write deadline of the connection out before each
+// write, so a download fails once the client stops reading for idle rather
+// than after a fixed time for the whole response
+type idleWriter struct {
+	w    io.Writer
+	rc   *http.ResponseController
+	idle time.Duration
+}
+
+func (iw *idleWriter) Write(p []byte) (int, error) {
+	// Writers that don't support deadlines just don't get one
+	iw.rc.SetWriteDeadline(time.Now().Add(iw.idle))
+	return iw.w.Write(p)
+}
+
+// downloadWriter returns the writer for the body of a download, which drops
+// clients that stall for DownloadIdleTimeout. Call done once the body is
+// written, so the deadline doesn't outlive the response on a reused
+// connection.
+func (h *Handlers) downloadWriter(w http.ResponseWriter) (_ io.Writer, done func()) {
+	if h.cfg.DownloadIdleTimeout <= 0 {
+		return w, func() {}
+	}
+	rc := http.NewResponseController(w)
+	return &idleWriter{w: w, rc: rc, idle: h.cfg.DownloadIdleTimeout}, func() {
+		rc.SetWriteDeadline(time.Time{})
+	}
+}
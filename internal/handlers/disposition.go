@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// errDisposition is returned for a ?disposition other than inline or attachment
+var errDisposition = errors.New("disposition must be inline or attachment")
+
+// parseDisposition reports whether the request asks for ?disposition=inline,
+// attachment is the default
+func parseDisposition(r *http.Request) (inline bool, err error) {
+	switch r.URL.Query().Get("disposition") {
+	case "", "attachment":
+		return false, nil
+	case "inline":
+		return true, nil
+	default:
+		return false, errDisposition
+	}
+}
+
+// disposition returns the Content-Disposition type for a download. Inline is
+// only honored for types browsers render without running scripts, others
+// need to be listed in INLINE_MIME_TYPES.
+func (h *Handlers) disposition(inline bool, mimeType string) string {
+	if inline && (safeInline(mimeType) || slices.Contains(h.cfg.InlineMimeTypes, baseMimeType(mimeType))) {
+		return "inline"
+	}
+	return "attachment"
+}
+
+// safeInline reports whether a type can be rendered inline without XSS risk.
+// HTML and SVG carry scripts, XML can too through XSLT.
+func safeInline(mimeType string) bool {
+	t := baseMimeType(mimeType)
+	switch {
+	case t == "text/html", t == "image/svg+xml", strings.HasSuffix(t, "xml"):
+		return false
+	case t == "application/pdf":
+		return true
+	}
+	return strings.HasPrefix(t, "text/") || strings.HasPrefix(t, "image/")
+}
+
+// baseMimeType strips parameters such as charset from a media type
+func baseMimeType(mimeType string) string {
+	t, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return ""
+	}
+	return t
+}
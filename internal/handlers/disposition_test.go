@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"inv/internal/config"
+)
+
+func TestParseDisposition(t *testing.T) {
+	tests := []struct {
+		query   string
+		inline  bool
+		wantErr bool
+	}{
+		{query: ""},
+		{query: "disposition=attachment"},
+		{query: "disposition=inline", inline: true},
+		{query: "disposition=INLINE", wantErr: true},
+		{query: "disposition=download", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			inline, err := parseDisposition(httptest.NewRequest(http.MethodGet, "/files/1?"+tt.query, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if inline != tt.inline {
+				t.Errorf("inline = %v, want %v", inline, tt.inline)
+			}
+		})
+	}
+}
+
+func TestDisposition(t *testing.T) {
+	tests := []struct {
+		name     string
+		inline   bool
+		mimeType string
+		allowed  []string
+		want     string
+	}{
+		{name: "attachment requested", mimeType: "image/png", want: "attachment"},
+		{name: "image", inline: true, mimeType: "image/png", want: "inline"},
+		{name: "text with charset", inline: true, mimeType: "text/plain; charset=utf-8", want: "inline"},
+		{name: "pdf", inline: true, mimeType: "application/pdf", want: "inline"},
+		{name: "html", inline: true, mimeType: "text/html", want: "attachment"},
+		{name: "html with charset", inline: true, mimeType: "text/html; charset=utf-8", want: "attachment"},
+		{name: "svg", inline: true, mimeType: "image/svg+xml", want: "attachment"},
+		{name: "xml", inline: true, mimeType: "text/xml", want: "attachment"},
+		{name: "binary", inline: true, mimeType: "application/octet-stream", want: "attachment"},
+		{name: "malformed", inline: true, mimeType: "image/", want: "attachment"},
+		{name: "allowlisted", inline: true, mimeType: "application/json", allowed: []string{"application/json"}, want: "inline"},
+		{name: "allowlisted with params", inline: true, mimeType: "application/json; charset=utf-8", allowed: []string{"application/json"}, want: "inline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handlers{cfg: config.Config{InlineMimeTypes: tt.allowed}}
+			if got := h.disposition(tt.inline, tt.mimeType); got != tt.want {
+				t.Errorf("disposition(%v, %q) = %q, want %q", tt.inline, tt.mimeType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"inv/internal/storage"
+)
+
+// DownloadURL returns a link downloading the file identified by the {id}
+// path value for PRESIGN_TTL. With a backend clients can download from
+// directly, such as S3, the link points there and the content bypasses the
+// service. Other backends, and content stored compressed or encrypted that
+// only the service can decode, get a signed GET /files/{id} URL instead,
+// which needs SIGNING_SECRET.
+func (h *Handlers) DownloadURL() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+
+		f, err := h.lookupFile(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load file from database", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to load file")
+			return
+		}
+
+		ttl := h.cfg.PresignTTL
+		presigner, ok := h.store.(storage.Presigner)
+		if !ok || f.compressed || f.encrypted {
+			if !h.signing() {
+				http.Error(w, "Signed download URLs are disabled", http.StatusNotFound)
+				return
+			}
+			link, expires := h.signedURL(id, ttl)
+			writeJSON(w, http.StatusOK, map[string]any{"url": link, "expires_at": expires, "direct": false})
+			return
+		}
+
+		mimeType := f.mimeType
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		expires := time.Now().Add(ttl).Truncate(time.Second).UTC()
+		link, err := presigner.PresignGet(r.Context(), f.key, ttl, f.filename, mimeType)
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to presign download", slog.String("error", err.Error()))
+			http.Error(w, "Failed to create download URL", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"url": link, "expires_at": expires, "direct": true})
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"inv/internal/config"
+)
+
+// Outcomes of an upload reported by a dry run
+const (
+	planInsert  = "insert"
+	planDedup   = "dedup"
+	planReplace = "replace"
+	planReject  = "reject"
+)
+
+// uploadPlan is the response to a dry run, what storing the upload would do
+type uploadPlan struct {
+	WouldInsert bool   `json:"would_insert"`
+	Action      string `json:"action"`
+	ExistingID  *int64 `json:"existing_id,omitempty"`
+	Filename    string `json:"filename"`
+	MimeType    string `json:"mime_type"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"`
+}
+
+// isDryRun reports whether the client asked for ?dry_run=true or sent
+// X-Dry-Run: true
+func isDryRun(r *http.Request) (bool, error) {
+	raw := r.URL.Query().Get("dry_run")
+	if raw == "" {
+		raw = r.Header.Get("X-Dry-Run")
+	}
+	if raw == "" {
+		return false, nil
+	}
+	dry, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.New("dry_run must be true or false")
+	}
+	return dry, nil
+}
+
+// planUpload works out what storing f would do under the ON_DUPLICATE and
+// dedup settings, without writing anything. Concurrent uploads may change
+// the outcome before the real upload.
+func (h *Handlers) planUpload(ctx context.Context, f newFile) (uploadPlan, error) {
+	p := uploadPlan{
+		Filename: f.Filename,
+		MimeType: f.MimeType,
+		Size:     int64(len(f.Content)),
+		Checksum: checksum(f.Content),
+	}
+
+	if h.cfg.OnDuplicate != config.DuplicateAllow {
+		var existing int64
+		err := h.db.QueryRowContext(ctx, fileByNameQuery, f.Owner, f.Filename).Scan(&existing)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return uploadPlan{}, fmt.Errorf("find file by name: %w", err)
+		case h.cfg.OnDuplicate == config.DuplicateReject:
+			p.Action, p.ExistingID = planReject, &existing
+			return p, nil
+		default:
+			p.Action, p.ExistingID = planReplace, &existing
+			return p, nil
+		}
+	}
+
+	if h.cfg.DedupUploads && f.TTL == 0 {
+		dup, ok, err := h.findDuplicate(ctx, f.Owner, p.Checksum)
+		if err != nil {
+			return uploadPlan{}, err
+		}
+		if ok {
+			p.Action, p.ExistingID = planDedup, &dup.ID
+			return p, nil
+		}
+	}
+
+	p.Action, p.WouldInsert = planInsert, true
+	return p, nil
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsDryRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		header  string
+		want    bool
+		wantErr bool
+	}{
+		{name: "neither"},
+		{name: "query", query: "dry_run=true", want: true},
+		{name: "header", header: "1", want: true},
+		{name: "query wins", query: "dry_run=false", header: "true"},
+		{name: "explicit false", header: "false"},
+		{name: "invalid", query: "dry_run=maybe", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/add?"+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set("X-Dry-Run", tt.header)
+			}
+			got, err := isDryRun(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("isDryRun = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
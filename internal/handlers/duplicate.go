@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"inv/internal/config"
+)
+
+// nameTakenError is returned by storeNamed under the reject policy
+type nameTakenError struct {
+	id int64 // the existing file
+}
+
+func (e *nameTakenError) Error() string {
+	return fmt.Sprintf("a file with this name already exists with ID: %d", e.id)
+}
+
+// errRepeatedName is returned by storeNamedBatch for a batch naming a file
+// twice, unless duplicates are allowed the policy can't apply to both
+var errRepeatedName = errors.New("the batch contains the same filename more than once")
+
+// fileByNameQuery finds the newest live file of an owner ($1) by name ($2)
+const fileByNameQuery = `
+        SELECT id FROM files
+        WHERE owner_id = $1 AND filename = $2 AND deleted_at IS NULL AND ` + notExpired + `
+        ORDER BY id DESC
+        LIMIT 1`
+
+// storeNamed stores f applying the ON_DUPLICATE policy to a live file of the
+// same owner and name, replaced reports whether an existing file was
+// overwritten. Uploads of the same name are serialized on an advisory lock
+// held until the file is stored, so concurrent uploads can't both miss
+// each other.
+func (h *Handlers) storeNamed(ctx context.Context, f newFile) (sf storedFile, replaced bool, err error) {
+	if h.cfg.OnDuplicate == config.DuplicateAllow {
+		sf, err = h.storeFile(ctx, f)
+		return sf, false, err
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storedFile{}, false, fmt.Errorf("begin name check: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockName(ctx, tx, f); err != nil {
+		return storedFile{}, false, err
+	}
+	var existing int64
+	err = tx.QueryRowContext(ctx, fileByNameQuery, f.Owner, f.Filename).Scan(&existing)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		sf, err = h.storeFile(ctx, f)
+	case err != nil:
+		return storedFile{}, false, fmt.Errorf("find file by name: %w", err)
+	case h.cfg.OnDuplicate == config.DuplicateReject:
+		return storedFile{}, false, &nameTakenError{id: existing}
+	default:
+		var rf replacedFile
+		rf, err = h.replaceFile(ctx, existing, upload{Filename: f.Filename, MimeType: f.MimeType, Content: f.Content}, "")
+		if err == nil {
+			h.forgetStale(existing)
+			sf, replaced = storedFile{ID: rf.ID, Size: rf.Size, Checksum: rf.Checksum, CreatedAt: rf.UpdatedAt}, true
+		}
+	}
+	if err != nil {
+		return storedFile{}, false, err
+	}
+
+	// The lock only guards the check, the file is stored either way
+	tx.Commit()
+	return sf, replaced, nil
+}
+
+// storeNamedBatch is storeNamed for the files of a batch, replaced reports
+// for each file whether it overwrote an existing one. New files are stored
+// with storeFiles. Under reject nothing is stored when any name is taken,
+// under replace the replacements made stay when a later one fails.
+func (h *Handlers) storeNamedBatch(ctx context.Context, files []newFile) (stored []storedFile, replaced []bool, err error) {
+	replaced = make([]bool, len(files))
+	if h.cfg.OnDuplicate == config.DuplicateAllow {
+		stored, err = h.storeFiles(ctx, files)
+		return stored, replaced, err
+	}
+
+	// Names are locked in a fixed order so concurrent batches can't deadlock
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Or(cmp.Compare(files[a].Owner, files[b].Owner), cmp.Compare(files[a].Filename, files[b].Filename))
+	})
+	for i := 1; i < len(order); i++ {
+		a, b := files[order[i-1]], files[order[i]]
+		if a.Owner == b.Owner && a.Filename == b.Filename {
+			return nil, nil, errRepeatedName
+		}
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("begin name check: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing := make([]int64, len(files)) // zero when the name is free
+	var fresh []int
+	for _, i := range order {
+		if err := lockName(ctx, tx, files[i]); err != nil {
+			return nil, nil, err
+		}
+		err := tx.QueryRowContext(ctx, fileByNameQuery, files[i].Owner, files[i].Filename).Scan(&existing[i])
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			fresh = append(fresh, i)
+		case err != nil:
+			return nil, nil, fmt.Errorf("find file by name: %w", err)
+		case h.cfg.OnDuplicate == config.DuplicateReject:
+			return nil, nil, &nameTakenError{id: existing[i]}
+		}
+	}
+	slices.Sort(fresh)
+
+	stored = make([]storedFile, len(files))
+	if len(fresh) > 0 {
+		batch := make([]newFile, len(fresh))
+		for j, i := range fresh {
+			batch[j] = files[i]
+		}
+		sfs, err := h.storeFiles(ctx, batch)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j, i := range fresh {
+			stored[i] = sfs[j]
+		}
+	}
+	for i, f := range files {
+		if existing[i] == 0 {
+			continue
+		}
+		rf, err := h.replaceFile(ctx, existing[i], upload{Filename: f.Filename, MimeType: f.MimeType, Content: f.Content}, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		h.forgetStale(existing[i])
+		stored[i], replaced[i] = storedFile{ID: rf.ID, Size: rf.Size, Checksum: rf.Checksum, CreatedAt: rf.UpdatedAt}, true
+	}
+
+	// The locks only guard the checks, the files are stored either way
+	tx.Commit()
+	return stored, replaced, nil
+}
+
+// lockName takes the advisory lock serializing uploads of f's owner and
+// name until tx ends
+func lockName(ctx context.Context, tx *sql.Tx, f newFile) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, f.Owner+"\x00"+f.Filename); err != nil {
+		return fmt.Errorf("lock filename: %w", err)
+	}
+	return nil
+}
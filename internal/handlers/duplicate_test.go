@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"inv/internal/config"
+	"inv/internal/testdb"
+)
+
+func TestOnDuplicate(t *testing.T) {
+	db := testdb.Open(t)
+	tests := []struct {
+		policy      string
+		wantStatus  int
+		wantSameID  bool
+		wantRows    int
+		wantContent string
+	}{
+		{policy: config.DuplicateAllow, wantStatus: http.StatusCreated, wantRows: 2, wantContent: "first"},
+		{policy: config.DuplicateReject, wantStatus: http.StatusConflict, wantRows: 1, wantContent: "first"},
+		{policy: config.DuplicateReplace, wantStatus: http.StatusOK, wantSameID: true, wantRows: 1, wantContent: "second"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			h := newTestHandlers(t, db, func(c *config.Config) { c.OnDuplicate = tt.policy })
+			owner := testOwner(t, db)
+			id := uploadFile(t, h, owner, "dup.txt", []byte("first"))
+
+			rec := httptest.NewRecorder()
+			h.AddFile()(rec, as(fileRequest(t, http.MethodPost, "/add", "dup.txt", []byte("second")), owner))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("second upload status = %d %q, want %d", rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+			if rec.Code != http.StatusConflict {
+				if sameID := responseID(t, rec) == id; sameID != tt.wantSameID {
+					t.Errorf("second upload kept id %v, want %v", sameID, tt.wantSameID)
+				}
+			}
+
+			var rows int
+			if err := db.QueryRow(`SELECT count(*) FROM files WHERE owner_id = $1 AND deleted_at IS NULL`, owner).Scan(&rows); err != nil {
+				t.Fatal(err)
+			}
+			if rows != tt.wantRows {
+				t.Errorf("%d files, want %d", rows, tt.wantRows)
+			}
+			if got := fileRequestFor(h.GetFile(), http.MethodGet, id, nil, owner).Body.String(); got != tt.wantContent {
+				t.Errorf("first file content = %q, want %q", got, tt.wantContent)
+			}
+		})
+	}
+}
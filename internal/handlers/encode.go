@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// msgpackType is the media type of MessagePack responses
+const msgpackType = "application/msgpack"
+
+// encodeResponse writes v as MessagePack to clients preferring it in their
+// Accept header, as JSON otherwise. Field names follow the json struct tags
+// either way.
+func encodeResponse(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Add("Vary", "Accept")
+	if !prefersMsgpack(r) {
+		writeJSON(w, status, v)
+		return
+	}
+	w.Header().Set("Content-Type", msgpackType)
+	w.WriteHeader(status)
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.Encode(v)
+}
+
+// prefersMsgpack reports whether the Accept header of r ranks MessagePack
+// above JSON. Wildcards count as JSON, the default.
+func prefersMsgpack(r *http.Request) bool {
+	var msgpackQ, jsonQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case msgpackType, "application/x-msgpack":
+			msgpackQ = max(msgpackQ, q)
+		case "application/json", "application/*", "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return msgpackQ > 0 && msgpackQ >= jsonQ
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder, the JSON value is
+// converted rather than sent as an opaque string
+func (j rawJSON) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if len(j) == 0 {
+		return enc.EncodeNil()
+	}
+	var v any
+	if err := json.Unmarshal(j, &v); err != nil {
+		return err
+	}
+	return enc.Encode(v)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestPrefersMsgpack(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"application/msgpack", true},
+		{"application/x-msgpack", true},
+		{"application/msgpack, application/json", true},
+		{"application/msgpack;q=0.5, application/json", false},
+		{"application/msgpack, */*;q=0.1", true},
+		{"application/msgpack;q=0", false},
+		{"application/msgpack;q=abc", false},
+		{"text/html, application/msgpack", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/files", nil)
+			r.Header.Set("Accept", tt.accept)
+			if got := prefersMsgpack(r); got != tt.want {
+				t.Errorf("prefersMsgpack(%q) = %v, want %v", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	v := struct {
+		ID       int64   `json:"id"`
+		Metadata rawJSON `json:"metadata"`
+	}{ID: 7, Metadata: rawJSON(`{"k":"v"}`)}
+
+	tests := []struct {
+		accept      string
+		contentType string
+		decode      func([]byte, any) error
+	}{
+		{accept: "application/json", contentType: "application/json", decode: json.Unmarshal},
+		{accept: "application/msgpack", contentType: msgpackType, decode: msgpack.Unmarshal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/files", nil)
+			r.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			encodeResponse(rec, r, http.StatusCreated, v)
+
+			if rec.Code != http.StatusCreated {
+				t.Errorf("status = %d, want 201", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if rec.Header().Get("Vary") != "Accept" {
+				t.Error("Vary: Accept missing")
+			}
+			var got map[string]any
+			if err := tt.decode(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			meta, _ := got["metadata"].(map[string]any)
+			if meta["k"] != "v" || got["id"] == nil {
+				t.Errorf("decoded %v", got)
+			}
+		})
+	}
+}
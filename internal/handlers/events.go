@@ -0,0 +1,32 @@
+package handlers
+
+import "time"
+
+// File event actions
+const (
+	ActionUploaded = "uploaded"
+	ActionDeleted  = "deleted"
+	ActionRestored = "restored"
+)
+
+// FileEvent describes a change to a stored file
+type FileEvent struct {
+	Action   string
+	ID       int64
+	Filename string
+	Size     int64
+	Checksum string
+	At       time.Time
+}
+
+// OnFileEvent registers fn to be called after each file change. Listeners
+// run on the request goroutine, so they must not block.
+func (h *Handlers) OnFileEvent(fn func(FileEvent)) {
+	h.listeners = append(h.listeners, fn)
+}
+
+func (h *Handlers) publish(e FileEvent) {
+	for _, fn := range h.listeners {
+		fn(e)
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"syscall"
+	"time"
+
+	"inv/internal/membudget"
+	"inv/internal/middlewares"
+)
+
+// maxFetchRedirects bounds the redirects followed by FetchFile, each hop is
+// checked against the SSRF guard like the first
+const maxFetchRedirects = 5
+
+// errBlockedAddress is returned when a fetch would connect to an address
+// inside the network
+var errBlockedAddress = errors.New("URL resolves to a private, loopback or otherwise internal address")
+
+// blockedPrefixes are networks FetchFile never connects to, on top of
+// loopback, private, link-local, multicast and unspecified addresses
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, embeds IPv4 addresses
+	netip.MustParsePrefix("2002::/16"),     // 6to4, embeds IPv4 addresses
+	netip.MustParsePrefix("fec0::/10"),     // deprecated site-local
+	netip.MustParsePrefix("255.255.255.255/32"),
+}
+
+// blockedAddress reports whether addr is internal to the network
+func blockedAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// newFetchClient returns the client of FetchFile. Addresses are checked as
+// the connection is made, after DNS resolution, so a hostname can't be
+// pointed at an internal address between a check and the request. Proxies
+// from the environment are ignored since they would bypass the check.
+func newFetchClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || blockedAddress(addr) {
+				return errBlockedAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// FetchFile downloads the content at a URL and stores it like an upload,
+// from a JSON body {"url", "filename"}. The filename defaults to the last
+// segment of the URL path. 404 unless FETCH_ENABLED is set.
+func (h *Handlers) FetchFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.fetcher == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req struct {
+			URL      string `json:"url"`
+			Filename string `json:"filename"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, `Body must be a JSON object like {"url": "https://example.com/a.pdf"}`)
+			return
+		}
+		src, err := url.Parse(req.URL)
+		if err != nil || (src.Scheme != "http" && src.Scheme != "https") || src.Host == "" {
+			writeError(w, http.StatusBadRequest, "url must be an absolute http or https URL")
+			return
+		}
+		name := req.Filename
+		if name == "" {
+			name = path.Base(src.Path)
+		}
+		filename, err := sanitizeFilename(name)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid filename, set one explicitly: "+err.Error())
+			return
+		}
+		if err := h.checkExtension(filename); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		u, release, ok := h.fetchUpload(w, r, src, filename)
+		if !ok {
+			return
+		}
+		defer release()
+
+		owner, _ := middlewares.PrincipalFrom(r.Context())
+		h.saveUpload(w, r, newFile{
+			Filename: u.Filename,
+			MimeType: u.MimeType,
+			Content:  u.Content,
+			Owner:    owner.Subject,
+			Metadata: "{}",
+		})
+	}
+}
+
+// fetchUpload downloads src within FetchMaxBytes and the memory budget. On
+// failure the error response has been written and ok is false.
+func (h *Handlers) fetchUpload(w http.ResponseWriter, r *http.Request, src *url.URL, filename string) (u upload, release func(), ok bool) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, src.String(), nil)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid url: "+err.Error())
+		return upload{}, nil, false
+	}
+	resp, err := h.fetcher.Do(req)
+	if err != nil {
+		h.writeFetchError(w, r, err)
+		return upload{}, nil, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("Remote server answered %s", resp.Status))
+		return upload{}, nil, false
+	}
+	limit := h.cfg.FetchMaxBytes
+	if resp.ContentLength > limit {
+		writeTooLarge(w, limit, resp.ContentLength)
+		return upload{}, nil, false
+	}
+
+	body := h.memory.Reader(io.LimitReader(resp.Body, limit+1))
+	content, err := io.ReadAll(body)
+	if err != nil {
+		body.Release()
+		if errors.Is(err, membudget.ErrExhausted) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Server is busy, retry later", http.StatusServiceUnavailable)
+			return upload{}, nil, false
+		}
+		h.writeFetchError(w, r, err)
+		return upload{}, nil, false
+	}
+	if int64(len(content)) > limit {
+		body.Release()
+		writeTooLarge(w, limit, 0)
+		return upload{}, nil, false
+	}
+	if len(content) == 0 && h.cfg.RejectEmptyUploads {
+		body.Release()
+		writeError(w, http.StatusBadRequest, "file is empty: "+filename)
+		return upload{}, nil, false
+	}
+
+	mimeType := detectMimeType(resp.Header.Get("Content-Type"), content)
+	if limit := h.sizeLimit(mimeType); int64(len(content)) > limit {
+		body.Release()
+		writeTooLarge(w, limit, int64(len(content)))
+		return upload{}, nil, false
+	}
+	return upload{Filename: filename, MimeType: mimeType, Content: content}, body.Release, true
+}
+
+// writeFetchError answers a failed download of the remote content
+func (h *Handlers) writeFetchError(w http.ResponseWriter, r *http.Request, err error) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errBlockedAddress):
+		writeError(w, http.StatusBadRequest, errBlockedAddress.Error())
+	case r.Context().Err() != nil && !errors.Is(r.Context().Err(), context.DeadlineExceeded):
+		// The client is gone
+	case errors.As(err, &netErr) && netErr.Timeout():
+		writeError(w, http.StatusGatewayTimeout, "Timed out fetching the URL")
+	default:
+		h.log(r.Context()).Warn(r.Context(), "Failed to fetch remote file", slog.String("error", err.Error()))
+		writeError(w, http.StatusBadGateway, "Failed to fetch the URL")
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"inv/internal/config"
+	"inv/internal/logging"
+	"inv/internal/membudget"
+)
+
+func TestBlockedAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"100.64.0.1", true},
+		{"198.18.0.1", true},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"2002:7f00:1::", true},
+		{"8.8.8.8", false},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+		{"::ffff:8.8.8.8", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := blockedAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("blockedAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchClientRefusesInternalAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer srv.Close()
+
+	_, err := newFetchClient(time.Second).Get(srv.URL)
+	if !errors.Is(err, errBlockedAddress) {
+		t.Errorf("Get(%s) error = %v, want errBlockedAddress", srv.URL, err)
+	}
+	if called {
+		t.Error("request reached the loopback server")
+	}
+}
+
+func TestFetchClientRedirects(t *testing.T) {
+	check := newFetchClient(time.Second).CheckRedirect
+	req := func(raw string) *http.Request {
+		u, _ := url.Parse(raw)
+		return &http.Request{URL: u}
+	}
+	tests := []struct {
+		name    string
+		target  string
+		hops    int
+		wantErr bool
+	}{
+		{name: "https", target: "https://example.com/a", hops: 1},
+		{name: "last allowed hop", target: "http://example.com/a", hops: maxFetchRedirects - 1},
+		{name: "too many hops", target: "http://example.com/a", hops: maxFetchRedirects, wantErr: true},
+		{name: "file scheme", target: "file:///etc/passwd", hops: 1, wantErr: true},
+		{name: "gopher scheme", target: "gopher://example.com/", hops: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			via := make([]*http.Request, tt.hops)
+			if err := check(req(tt.target), via); (err != nil) != tt.wantErr {
+				t.Errorf("CheckRedirect error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		enabled bool
+		body    string
+		status  int
+	}{
+		{name: "disabled", body: `{"url": "https://example.com/a.txt"}`, status: http.StatusNotFound},
+		{name: "not json", enabled: true, body: `url=x`, status: http.StatusBadRequest},
+		{name: "relative url", enabled: true, body: `{"url": "/a.txt"}`, status: http.StatusBadRequest},
+		{name: "file scheme", enabled: true, body: `{"url": "file:///etc/passwd"}`, status: http.StatusBadRequest},
+		{name: "no filename", enabled: true, body: `{"url": "https://example.com/"}`, status: http.StatusBadRequest},
+		{name: "loopback", enabled: true, body: `{"url": "` + srv.URL + `/a.txt"}`, status: http.StatusBadRequest},
+		{name: "metadata service", enabled: true, body: `{"url": "http://169.254.169.254/latest/meta-data/x"}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handlers{
+				logger: logging.Discard,
+				cfg:    config.Config{FetchMaxBytes: 1 << 20},
+				memory: membudget.New(0),
+			}
+			if tt.enabled {
+				h.fetcher = newFetchClient(time.Second)
+			}
+			rec := httptest.NewRecorder()
+			h.FetchFile()(rec, httptest.NewRequest(http.MethodPost, "/fetch", strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
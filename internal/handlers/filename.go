@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFilenameLength matches the files.filename VARCHAR(255) column
+const maxFilenameLength = 255
+
+// normalizeExtensions lowercases the ALLOWED_EXTENSIONS entries and gives
+// each a leading dot
+func normalizeExtensions(exts []string) []string {
+	out := make([]string, 0, len(exts))
+	for _, ext := range exts {
+		out = append(out, "."+strings.TrimPrefix(strings.ToLower(ext), "."))
+	}
+	return out
+}
+
+// checkExtension rejects a sanitized filename whose extension isn't in
+// ALLOWED_EXTENSIONS, listing the allowed ones. Entries are matched as
+// suffixes so multi-part extensions like .tar.gz work.
+func (h *Handlers) checkExtension(filename string) error {
+	if len(h.extensions) == 0 {
+		return nil
+	}
+	name := strings.ToLower(filename)
+	if path.Ext(strings.TrimPrefix(name, ".")) == "" {
+		if h.cfg.AllowNoExtension {
+			return nil
+		}
+		return fmt.Errorf("filename must have an extension, allowed: %s", strings.Join(h.extensions, ", "))
+	}
+	for _, ext := range h.extensions {
+		if len(name) > len(ext) && strings.HasSuffix(name, ext) {
+			return nil
+		}
+	}
+	return fmt.Errorf("extension %s is not allowed, allowed: %s", path.Ext(name), strings.Join(h.extensions, ", "))
+}
+
+// sanitizeFilename reduces a client supplied filename to its last path
+// component, truncated to maxFilenameLength characters with the extension
+// kept. Names that try to walk up directories or contain control characters
+// are rejected.
+func sanitizeFilename(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", errors.New("filename must be valid UTF-8")
+	}
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		return "", errors.New("filename must not contain control characters")
+	}
+
+	// Clients on Windows send backslash separated paths
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	for _, seg := range strings.Split(slashed, "/") {
+		if seg == ".." {
+			return "", errors.New("filename must not contain .. path segments")
+		}
+	}
+	base := path.Base(slashed)
+	if base == "." || base == "/" || strings.TrimSpace(base) == "" {
+		return "", errors.New("filename is empty")
+	}
+
+	if utf8.RuneCountInString(base) > maxFilenameLength {
+		ext := path.Ext(base)
+		if utf8.RuneCountInString(ext) > maxFilenameLength/2 {
+			ext = ""
+		}
+		stem := []rune(strings.TrimSuffix(base, ext))
+		base = string(stem[:maxFilenameLength-utf8.RuneCountInString(ext)]) + ext
+	}
+	return base, nil
+}
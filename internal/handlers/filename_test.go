@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"inv/internal/config"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", in: "report.pdf", want: "report.pdf"},
+		{name: "unix path", in: "/home/me/report.pdf", want: "report.pdf"},
+		{name: "windows path", in: `C:\Users\me\report.pdf`, want: "report.pdf"},
+		{name: "dot file", in: ".env", want: ".env"},
+		{name: "unicode", in: "résumé.pdf", want: "résumé.pdf"},
+		{name: "parent segment", in: "../etc/passwd", wantErr: true},
+		{name: "windows parent segment", in: `..\secret.txt`, wantErr: true},
+		{name: "trailing parent", in: "a/..", wantErr: true},
+		{name: "dots in name", in: "a..b.txt", want: "a..b.txt"},
+		{name: "empty", in: "", wantErr: true},
+		{name: "blank", in: "   ", wantErr: true},
+		{name: "directory", in: "dir/", want: "dir"},
+		{name: "root", in: "/", wantErr: true},
+		{name: "current dir", in: ".", wantErr: true},
+		{name: "newline", in: "a\nb.txt", wantErr: true},
+		{name: "nul", in: "a\x00.txt", wantErr: true},
+		{name: "invalid utf8", in: "a\xff.txt", wantErr: true},
+		{name: "long keeps extension", in: long + ".pdf", want: long[:maxFilenameLength-4] + ".pdf"},
+		{name: "long extension dropped", in: "a." + long, want: ("a." + long)[:maxFilenameLength]},
+		{name: "long multibyte", in: strings.Repeat("é", 300) + ".txt", want: strings.Repeat("é", maxFilenameLength-4) + ".txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sanitizeFilename(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sanitizeFilename(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if n := utf8.RuneCountInString(got); n > maxFilenameLength {
+				t.Errorf("result has %d characters, over %d", n, maxFilenameLength)
+			}
+		})
+	}
+}
+
+func TestCheckExtension(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     []string
+		noExtension bool
+		filename    string
+		wantErr     bool
+	}{
+		{name: "no allowlist", filename: "run.exe"},
+		{name: "allowed", allowed: []string{"pdf", ".PNG"}, filename: "a.pdf"},
+		{name: "case insensitive", allowed: []string{"pdf", ".PNG"}, filename: "A.Png"},
+		{name: "not allowed", allowed: []string{"pdf"}, filename: "run.exe", wantErr: true},
+		{name: "suffix only", allowed: []string{"pdf"}, filename: "a.pdf.exe", wantErr: true},
+		{name: "multi-part extension", allowed: []string{"tar.gz"}, filename: "backup.tar.gz"},
+		{name: "multi-part needs all parts", allowed: []string{"tar.gz"}, filename: "backup.gz", wantErr: true},
+		{name: "bare extension", allowed: []string{"pdf"}, filename: ".pdf", wantErr: true},
+		{name: "no extension refused", allowed: []string{"pdf"}, filename: "README", wantErr: true},
+		{name: "no extension allowed", allowed: []string{"pdf"}, noExtension: true, filename: "README"},
+		{name: "dot file has no extension", allowed: []string{"pdf"}, noExtension: true, filename: ".env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handlers{
+				cfg:        config.Config{AllowNoExtension: tt.noExtension},
+				extensions: normalizeExtensions(tt.allowed),
+			}
+			if err := h.checkExtension(tt.filename); (err != nil) != tt.wantErr {
+				t.Errorf("checkExtension(%q) error = %v, wantErr %v", tt.filename, err, tt.wantErr)
+			}
+		})
+	}
+}
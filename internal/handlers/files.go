@@ -0,0 +1,694 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"inv/internal/config"
+	"inv/internal/dberr"
+	"inv/internal/middlewares"
+	"inv/internal/storage"
+)
+
+// AddFile stores an uploaded multipart "file" field.
+func (h *Handlers) AddFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, release, ok := h.readUpload(w, r)
+		if !ok {
+			return
+		}
+		defer release()
+
+		// Optional metadata object stored alongside the file
+		metadata, err := parseMetadata(r.FormValue("metadata"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ttl, err := parseTTL(r.FormValue("ttl_seconds"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Save the file, owned by the caller
+		owner, _ := middlewares.PrincipalFrom(r.Context())
+		f := newFile{
+			Filename: u.Filename,
+			MimeType: u.MimeType,
+			Content:  u.Content,
+			Owner:    owner.Subject,
+			Metadata: metadata,
+			TTL:      ttl,
+		}
+		h.saveUpload(w, r, f)
+	}
+}
+
+// AddRawFile stores the raw request body as a file named by the X-Filename
+// header, for clients that can't easily build multipart bodies. Metadata
+// comes from the X-File-Metadata header and the expiry from ?ttl_seconds=.
+func (h *Handlers) AddRawFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, release, ok := h.readRawUpload(w, r)
+		if !ok {
+			return
+		}
+		defer release()
+
+		metadata, err := parseMetadata(r.Header.Get("X-File-Metadata"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ttl, err := parseTTL(r.URL.Query().Get("ttl_seconds"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		owner, _ := middlewares.PrincipalFrom(r.Context())
+		h.saveUpload(w, r, newFile{
+			Filename: u.Filename,
+			MimeType: u.MimeType,
+			Content:  u.Content,
+			Owner:    owner.Subject,
+			Metadata: metadata,
+			TTL:      ttl,
+		})
+	}
+}
+
+// saveUpload stores f for an upload request and writes the response, going
+// through saveIdempotent when the client sent an Idempotency-Key. A dry run
+// answers with what storing it would do instead.
+func (h *Handlers) saveUpload(w http.ResponseWriter, r *http.Request, f newFile) {
+	dry, err := isDryRun(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if dry {
+		plan, err := h.planUpload(r.Context(), f)
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to plan upload", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to check upload")
+			return
+		}
+		writeJSON(w, http.StatusOK, plan)
+		return
+	}
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		h.saveIdempotent(w, r, f, key)
+		return
+	}
+	h.storeUpload(w, r, f)
+}
+
+// storeUpload stores f and writes the response: 201 with the new id, 200
+// when an existing file was returned or replaced, or 202 with a job to poll
+// for large files. It returns the id of the file, zero when there's none yet.
+func (h *Handlers) storeUpload(w http.ResponseWriter, r *http.Request, f newFile) int64 {
+	// Large files are stored in the background so the client isn't held
+	// up by the database, it can poll the job for the file id. With a
+	// spool every upload is, from a local file surviving restarts.
+	async := h.cfg.AsyncUploadThreshold > 0 && int64(len(f.Content)) >= h.cfg.AsyncUploadThreshold
+	if (async || h.spool != nil) && h.cfg.OnDuplicate == config.DuplicateAllow {
+		var jobID string
+		var err error
+		if h.spool != nil {
+			jobID, err = h.storeSpooled(f, h.newAuditEntry(r, AuditUpload, 0))
+		} else {
+			jobID, err = h.storeAsync(r.Context(), f, h.newAuditEntry(r, AuditUpload, 0))
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to start upload job", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to save file to database")
+			return 0
+		}
+		w.Header().Set("Location", "/jobs/"+jobID)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("File accepted for processing with job ID: " + jobID))
+		return 0
+	}
+
+	stored, replaced, err := h.storeNamed(r.Context(), f)
+	if errors.Is(err, context.Canceled) {
+		return 0
+	}
+	if taken := (*nameTakenError)(nil); errors.As(err, &taken) {
+		writeError(w, http.StatusConflict, taken.Error())
+		return 0
+	}
+	if err != nil {
+		h.log(r.Context()).Error(r.Context(), "Failed to save file", slog.String("error", err.Error()))
+		writeDBError(w, err, "Failed to save file to database")
+		return 0
+	}
+	h.audit(r.Context(), h.newAuditEntry(r, AuditUpload, stored.ID))
+
+	// Response
+	if replaced {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("File replaced with ID: " + strconv.FormatInt(stored.ID, 10)))
+		return stored.ID
+	}
+	if stored.Deduped {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("File already exists with ID: " + strconv.FormatInt(stored.ID, 10)))
+		return stored.ID
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("File uploaded successfully with ID: " + strconv.FormatInt(stored.ID, 10)))
+	return stored.ID
+}
+
+// ReplaceFile replaces the content of the file identified by the {id} path
+// value with the multipart "file" field, keeping its id, name and metadata.
+// With If-Match it only does so while the file still has that ETag.
+func (h *Handlers) ReplaceFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+
+		u, release, ok := h.readUpload(w, r)
+		if !ok {
+			return
+		}
+		defer release()
+
+		replaced, err := h.replaceFile(r.Context(), id, u, r.Header.Get("If-Match"))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errStale) {
+			writeError(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to replace file", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to replace file")
+			return
+		}
+		h.forgetStale(id)
+
+		setETag(w, sql.NullString{String: replaced.Checksum, Valid: true})
+		writeJSON(w, http.StatusOK, replaced)
+	}
+}
+
+// GetFile serves the content of the file identified by the {id} path value.
+func (h *Handlers) GetFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+		inline, err := parseDisposition(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Keep a single popular file from monopolizing the database
+		if !h.downloads.acquire(id) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many concurrent downloads of this file", http.StatusServiceUnavailable)
+			return
+		}
+		defer h.downloads.release(id)
+
+		f, err := h.lookupFile(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			h.forgetStale(id)
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load file from database", slog.String("error", err.Error()))
+			if h.serveStale(w, r, id, inline) {
+				return
+			}
+			writeDBError(w, err, "Failed to load file")
+			return
+		}
+
+		content, err := h.openContent(r.Context(), f)
+		if errors.Is(err, storage.ErrNotFound) {
+			h.writeContentMissing(w, r, id)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load file content", slog.String("error", err.Error()))
+			if h.serveStale(w, r, id, inline) {
+				return
+			}
+			writeDBError(w, err, "Failed to load file")
+			return
+		}
+		defer content.Close()
+
+		// Compressed content is passed through to clients that accept gzip
+		var body io.Reader = content
+		if f.compressed {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if acceptsGzip(r) {
+				w.Header().Set("Content-Encoding", "gzip")
+			} else if body, err = gzip.NewReader(content); err != nil {
+				h.log(r.Context()).Error(r.Context(), "Failed to decompress file", slog.String("error", err.Error()))
+				writeDBError(w, err, "Failed to load file")
+				return
+			}
+		}
+
+		// Keep a copy of small decoded files to serve if the database goes away
+		var capture *captureWriter
+		if h.stale != nil && w.Header().Get("Content-Encoding") == "" {
+			capture = &captureWriter{limit: h.stale.maxEntry()}
+			body = io.TeeReader(body, capture)
+		}
+
+		h.audit(r.Context(), h.newAuditEntry(r, AuditDownload, id))
+		writeFileHeaders(w, f.filename, f.mimeType, f.metadata, h.disposition(inline, f.mimeType))
+		setETag(w, f.checksum)
+		// The stored size is of the decoded file, passed through gzip
+		// content is sent chunked
+		if w.Header().Get("Content-Encoding") == "" {
+			w.Header().Set("Content-Length", strconv.FormatInt(f.size, 10))
+		}
+		out, done := h.downloadWriter(w)
+		defer done()
+		if _, err := io.Copy(out, body); err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to send file", slog.String("error", err.Error()))
+			return
+		}
+		if capture != nil && !capture.overflow {
+			h.stale.put(&cachedFile{id: id, owner: f.owner, filename: f.filename, mimeType: f.mimeType, metadata: f.metadata, content: capture.buf.Bytes()})
+		}
+	}
+}
+
+// HeadFile answers with the headers GetFile would send, without loading the
+// content. Content-Length is the stored size of the decoded file.
+func (h *Handlers) HeadFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+		inline, err := parseDisposition(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		f, err := h.lookupFile(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load file from database", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to load file")
+			return
+		}
+
+		writeFileHeaders(w, f.filename, f.mimeType, f.metadata, h.disposition(inline, f.mimeType))
+		setETag(w, f.checksum)
+		w.Header().Set("Content-Length", strconv.FormatInt(f.size, 10))
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// fileMeta is the JSON description of a file served by FileMeta
+type fileMeta struct {
+	ID        int64     `json:"id"`
+	Filename  string    `json:"filename"`
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	Checksum  *string   `json:"checksum"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FileMeta returns the description of the file identified by the {id} path
+// value as JSON or MessagePack, without touching its content.
+func (h *Handlers) FileMeta() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+
+		var m fileMeta
+		err = h.fileMetaStmt.QueryRowContext(r.Context(), id, readScope(r.Context())).Scan(&m.ID, &m.Filename, &m.MimeType, &m.Size, &m.Checksum, &m.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load file from database", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to load file")
+			return
+		}
+		encodeResponse(w, r, http.StatusOK, m)
+	}
+}
+
+// fileInfo is the stored description of a file
+type fileInfo struct {
+	filename, mimeType string
+	metadata           rawJSON
+	compressed         bool
+	key                string
+	size               int64
+	checksum           sql.NullString // NULL for files uploaded before checksums
+	encrypted          bool
+	owner              string
+}
+
+// lookupFile loads the description of a live file the caller may see,
+// sql.ErrNoRows if there's none
+func (h *Handlers) lookupFile(ctx context.Context, id int64) (fileInfo, error) {
+	return scanFileInfo(h.getFileStmt.QueryRowContext(ctx, id, readScope(ctx)))
+}
+
+// lookupFileTx is lookupFile within tx
+func (h *Handlers) lookupFileTx(ctx context.Context, tx *sql.Tx, id int64) (fileInfo, error) {
+	return scanFileInfo(tx.StmtContext(ctx, h.getFileStmt).QueryRowContext(ctx, id, readScope(ctx)))
+}
+
+// scanFileInfo reads a row selected by getFileStmt
+func scanFileInfo(row *sql.Row) (fileInfo, error) {
+	var f fileInfo
+	err := row.Scan(&f.filename, &f.mimeType, &f.metadata, &f.compressed, &f.key, &f.size, &f.checksum, &f.encrypted, &f.owner)
+	return f, err
+}
+
+// setETag uses the content checksum as a strong validator when there is one
+func setETag(w http.ResponseWriter, checksum sql.NullString) {
+	if checksum.Valid {
+		w.Header().Set("ETag", `"`+checksum.String+`"`)
+	}
+}
+
+// etagMatches reports whether an If-Match header value is satisfied by the
+// ETag of a file with checksum. "*" matches any file, weak tags never match
+// since If-Match compares strongly.
+func etagMatches(header string, checksum sql.NullString) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if checksum.Valid && tag == `"`+checksum.String+`"` {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFileHeaders sets the headers describing a downloaded file,
+// disposition is inline or attachment
+func writeFileHeaders(w http.ResponseWriter, filename, mimeType string, metadata rawJSON, disposition string) {
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("X-File-Metadata", string(metadata))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+}
+
+// serveStale answers from the stale cache after a storage failure, flagging
+// the response with a Warning header. It reports false when nothing is
+// cached, or the cached file isn't the caller's to see.
+func (h *Handlers) serveStale(w http.ResponseWriter, r *http.Request, id int64, inline bool) bool {
+	if h.stale == nil {
+		return false
+	}
+	f, ok := h.stale.get(id)
+	if !ok {
+		return false
+	}
+	if scope := readScope(r.Context()); scope.Valid && scope.String != f.owner {
+		return false
+	}
+	w.Header().Set("Warning", `111 - "Revalidation Failed"`)
+	writeFileHeaders(w, f.filename, f.mimeType, f.metadata, h.disposition(inline, f.mimeType))
+	w.Header().Set("Content-Length", strconv.Itoa(len(f.content)))
+	w.Write(f.content)
+	return true
+}
+
+// writeContentMissing answers a read of file id whose content is gone with
+// 404. That's expected when the file was purged since it was looked up, and
+// means the content was lost otherwise, which is logged.
+func (h *Handlers) writeContentMissing(w http.ResponseWriter, r *http.Request, id int64) {
+	h.forgetStale(id)
+	if _, err := h.lookupFile(r.Context(), id); errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	h.log(r.Context()).Error(r.Context(), "File content is missing", slog.Int64("file_id", id))
+	http.Error(w, "File content not found", http.StatusNotFound)
+}
+
+// forgetStale drops a file from the stale cache once it's gone or changed
+func (h *Handlers) forgetStale(id int64) {
+	if h.stale != nil {
+		h.stale.remove(id)
+	}
+}
+
+// errNotMultipart is returned for uploads that aren't multipart/form-data
+var errNotMultipart = errors.New(`Content-Type must be multipart/form-data with the upload in a "file" field`)
+
+// checkMultipart reports a Content-Type other than multipart/form-data, or
+// one whose boundary parameter is missing or not a valid RFC 2046 boundary
+// (1 to 70 characters, not ending in a space).
+func checkMultipart(contentType string) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		return errNotMultipart
+	}
+	boundary, ok := params["boundary"]
+	if !ok || boundary == "" {
+		return errors.New("multipart Content-Type is missing the boundary parameter")
+	}
+	if len(boundary) > 70 || strings.HasSuffix(boundary, " ") {
+		return errors.New("multipart boundary must be 1 to 70 characters and not end with a space")
+	}
+	return nil
+}
+
+// TransferFile reassigns the file identified by the {id} path value to the
+// owner_id in the JSON body. With ?copy=true the row and its content are
+// duplicated for the new owner instead and the copy's id is returned.
+func (h *Handlers) TransferFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			OwnerID string `json:"owner_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OwnerID == "" {
+			http.Error(w, "Body must be a JSON object with an owner_id", http.StatusBadRequest)
+			return
+		}
+
+		var newID int64
+		if r.URL.Query().Get("copy") == "true" {
+			newID, err = h.copyFile(r.Context(), id, req.OwnerID)
+		} else {
+			err = h.transferFileStmt.QueryRowContext(r.Context(), id, req.OwnerID).Scan(&newID)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if dberr.IsUniqueViolation(err) {
+			http.Error(w, "The new owner already has an identical file", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to transfer file", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to transfer file")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"id": newID, "owner_id": req.OwnerID})
+	}
+}
+
+// copyFile duplicates the row and stored content of file id for owner,
+// returning the id of the copy. The row is committed once the content is
+// stored.
+func (h *Handlers) copyFile(ctx context.Context, id int64, owner string) (int64, error) {
+	key, err := storage.NewKey()
+	if err != nil {
+		return 0, fmt.Errorf("generate storage key: %w", err)
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin copy: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		newID  int64
+		srcKey string
+	)
+	if err := tx.StmtContext(ctx, h.copyFileStmt).QueryRowContext(ctx, id, owner, key).Scan(&newID, &srcKey); err != nil {
+		return 0, err
+	}
+
+	src, err := h.store.Get(ctx, srcKey)
+	if err != nil {
+		return 0, fmt.Errorf("load content: %w", err)
+	}
+	defer src.Close()
+	if err := h.putContent(ctx, tx, key, src); err != nil {
+		tx.Rollback()
+		h.deleteContent(ctx, key)
+		return 0, fmt.Errorf("store content: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		h.deleteContent(ctx, key)
+		return 0, fmt.Errorf("commit copy: %w", err)
+	}
+	return newID, nil
+}
+
+// DeleteFile soft-deletes the file identified by the {id} path value. The
+// row is kept, hidden from reads, until RestoreFile or a purge.
+func (h *Handlers) DeleteFile() http.HandlerFunc {
+	return h.setDeleted(h.deleteFileStmt, AuditDelete, "Failed to delete file")
+}
+
+// RestoreFile undoes a soft delete of the file identified by the {id} path value
+func (h *Handlers) RestoreFile() http.HandlerFunc {
+	return h.setDeleted(h.restoreFileStmt, AuditRestore, "Failed to restore file")
+}
+
+// setDeleted runs stmt for the {id} path value, answering 404 when no row
+// changed, and records action in the audit log
+func (h *Handlers) setDeleted(stmt *sql.Stmt, action, failure string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+
+		res, err := stmt.ExecContext(r.Context(), id, readScope(r.Context()))
+		if dberr.IsUniqueViolation(err) {
+			http.Error(w, "An identical file already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), failure, slog.String("error", err.Error()))
+			writeDBError(w, err, failure)
+			return
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		h.forgetStale(id)
+		h.audit(r.Context(), h.newAuditEntry(r, action, id))
+
+		event := ActionDeleted
+		if action == AuditRestore {
+			event = ActionRestored
+		}
+		h.publish(FileEvent{Action: event, ID: id, At: time.Now()})
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// checksum returns the hex encoded SHA-256 of content
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// maxTTL is the longest accepted ttl_seconds, ten years
+const maxTTL = 10 * 365 * 24 * 60 * 60
+
+// parseTTL parses the optional ttl_seconds form field, zero when absent
+func parseTTL(raw string) (int64, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	ttl, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || ttl <= 0 || ttl > maxTTL {
+		return 0, fmt.Errorf("ttl_seconds must be an integer between 1 and %d", maxTTL)
+	}
+	return ttl, nil
+}
+
+// detectMimeType keeps the declared type unless it's missing or generic, in
+// which case the type is sniffed from the first 512 bytes of content
+func detectMimeType(declared string, content []byte) string {
+	if declared != "" && declared != "application/octet-stream" {
+		return declared
+	}
+	return http.DetectContentType(content)
+}
+
+// sizeMismatchError reports an upload whose length differs from the one
+// the client declared, meaning it was cut off or padded on the way
+type sizeMismatchError struct {
+	Expected, Read int64
+}
+
+func (e *sizeMismatchError) Error() string {
+	return fmt.Sprintf("file size mismatch: expected %d bytes, read %d", e.Expected, e.Read)
+}
+
+// checkDeclaredSize compares the number of bytes read for a part with the
+// size recorded by the multipart reader and any Content-Length the client
+// declared on the part itself.
+func checkDeclaredSize(header *multipart.FileHeader, n int64) error {
+	if header.Size != n {
+		return &sizeMismatchError{Expected: header.Size, Read: n}
+	}
+	if declared := header.Header.Get("Content-Length"); declared != "" {
+		size, err := strconv.ParseInt(declared, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid part Content-Length %q", declared)
+		}
+		if size != n {
+			return &sizeMismatchError{Expected: size, Read: n}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"inv/internal/config"
+	"inv/internal/testdb"
+)
+
+func TestETagMatches(t *testing.T) {
+	sum := sql.NullString{String: "abc123", Valid: true}
+	tests := []struct {
+		name     string
+		header   string
+		checksum sql.NullString
+		want     bool
+	}{
+		{name: "exact", header: `"abc123"`, checksum: sum, want: true},
+		{name: "in list", header: `"zzz", "abc123"`, checksum: sum, want: true},
+		{name: "wildcard", header: "*", checksum: sum, want: true},
+		{name: "wildcard without checksum", header: "*", want: true},
+		{name: "other tag", header: `"zzz"`, checksum: sum},
+		{name: "weak tag", header: `W/"abc123"`, checksum: sum},
+		{name: "unquoted", header: "abc123", checksum: sum},
+		{name: "no checksum", header: `""`},
+		{name: "empty header", checksum: sum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.header, tt.checksum); got != tt.want {
+				t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDeclaredSize(t *testing.T) {
+	header := func(size int64, contentLength string) *multipart.FileHeader {
+		h := &multipart.FileHeader{Size: size, Header: textproto.MIMEHeader{}}
+		if contentLength != "" {
+			h.Header.Set("Content-Length", contentLength)
+		}
+		return h
+	}
+	tests := []struct {
+		name     string
+		header   *multipart.FileHeader
+		read     int64
+		mismatch bool
+		wantErr  bool
+	}{
+		{name: "matching", header: header(10, ""), read: 10},
+		{name: "matching part length", header: header(10, "10"), read: 10},
+		{name: "short read", header: header(10, ""), read: 8, mismatch: true, wantErr: true},
+		{name: "part length differs", header: header(10, "12"), read: 10, mismatch: true, wantErr: true},
+		{name: "invalid part length", header: header(10, "ten"), read: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDeclaredSize(tt.header, tt.read)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var mismatch *sizeMismatchError
+			if errors.As(err, &mismatch) != tt.mismatch {
+				t.Errorf("error = %v, want a size mismatch: %v", err, tt.mismatch)
+			}
+			if tt.mismatch && mismatch.Read != tt.read {
+				t.Errorf("Read = %d, want %d", mismatch.Read, tt.read)
+			}
+		})
+	}
+}
+
+func TestCheckMultipart(t *testing.T) {
+	tests := []struct {
+		contentType string
+		wantErr     bool
+	}{
+		{contentType: "multipart/form-data; boundary=abc"},
+		{contentType: `multipart/form-data; boundary="with space"`},
+		{contentType: "", wantErr: true},
+		{contentType: "application/json", wantErr: true},
+		{contentType: "multipart/mixed; boundary=abc", wantErr: true},
+		{contentType: "multipart/form-data", wantErr: true},
+		{contentType: "multipart/form-data; boundary=", wantErr: true},
+		{contentType: `multipart/form-data; boundary="ends with "`, wantErr: true},
+		{contentType: "multipart/form-data; boundary=" + strings.Repeat("a", 71), wantErr: true},
+		{contentType: "multipart/form-data; boundary=" + strings.Repeat("a", 70)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			if err := checkMultipart(tt.contentType); (err != nil) != tt.wantErr {
+				t.Errorf("checkMultipart(%q) error = %v, wantErr %v", tt.contentType, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	tests := []struct {
+		name     string
+		declared string
+		content  []byte
+		want     string
+	}{
+		{name: "declared kept", declared: "application/pdf", content: png, want: "application/pdf"},
+		{name: "missing sniffed", content: png, want: "image/png"},
+		{name: "generic sniffed", declared: "application/octet-stream", content: []byte("hello"), want: "text/plain; charset=utf-8"},
+		{name: "empty content", want: "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectMimeType(tt.declared, tt.content); got != tt.want {
+				t.Errorf("detectMimeType = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int64
+		wantErr bool
+	}{
+		{raw: "", want: 0},
+		{raw: "60", want: 60},
+		{raw: strconv.Itoa(maxTTL), want: maxTTL},
+		{raw: strconv.Itoa(maxTTL + 1), wantErr: true},
+		{raw: "0", wantErr: true},
+		{raw: "-5", wantErr: true},
+		{raw: "1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseTTL(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseTTL(%q) = %d, want %d", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+// replaceRequest replaces file id of owner with content
+func replaceRequest(t *testing.T, h *Handlers, owner string, id int64, content []byte, ifMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := fileRequest(t, http.MethodPut, "/files/"+strconv.FormatInt(id, 10), "new.txt", content)
+	r.SetPathValue("id", strconv.FormatInt(id, 10))
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	h.ReplaceFile()(rec, as(r, owner))
+	return rec
+}
+
+// storageKey returns the storage key of file id
+func storageKey(t *testing.T, db *sql.DB, id int64) string {
+	t.Helper()
+	var key string
+	if err := db.QueryRow(`SELECT storage_key FROM files WHERE id = $1`, id).Scan(&key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestReplaceFile(t *testing.T) {
+	db := testdb.Open(t)
+	h := newTestHandlers(t, db, nil)
+	owner, other := testOwner(t, db), testOwner(t, db)
+	id := uploadFile(t, h, owner, "doc.txt", []byte("old content"))
+	oldKey := storageKey(t, db, id)
+
+	tests := []struct {
+		name    string
+		subject string
+		id      int64
+		want    int
+	}{
+		{name: "missing file", subject: owner, id: id + 1_000_000, want: http.StatusNotFound},
+		{name: "other user", subject: other, id: id, want: http.StatusNotFound},
+		{name: "owner", subject: owner, id: id, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := replaceRequest(t, h, tt.subject, tt.id, []byte("new content"), ""); rec.Code != tt.want {
+				t.Errorf("status = %d %q, want %d", rec.Code, rec.Body.String(), tt.want)
+			}
+		})
+	}
+
+	rec := fileRequestFor(h.GetFile(), http.MethodGet, id, nil, owner)
+	if rec.Code != http.StatusOK || rec.Body.String() != "new content" {
+		t.Errorf("GET = %d %q, want 200 %q", rec.Code, rec.Body.String(), "new content")
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "doc.txt") {
+		t.Errorf("Content-Disposition = %q, want the original name kept", rec.Header().Get("Content-Disposition"))
+	}
+	if storageKey(t, db, id) == oldKey {
+		t.Error("content was written under the old storage key")
+	}
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM files WHERE storage_key = $1`, oldKey).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("%d rows still use the old storage key", n)
+	}
+}
+
+func TestReplaceFileIfMatch(t *testing.T) {
+	db := testdb.Open(t)
+	h := newTestHandlers(t, db, nil)
+	owner := testOwner(t, db)
+
+	tests := []struct {
+		name    string
+		ifMatch func(etag string) string
+		want    int
+		content string
+	}{
+		{name: "current", ifMatch: func(etag string) string { return etag }, want: http.StatusOK, content: "v2"},
+		{name: "any", ifMatch: func(string) string { return "*" }, want: http.StatusOK, content: "v2"},
+		{name: "stale", ifMatch: func(string) string { return `"0000"` }, want: http.StatusPreconditionFailed, content: "v1"},
+		{name: "weak", ifMatch: func(etag string) string { return "W/" + etag }, want: http.StatusPreconditionFailed, content: "v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := uploadFile(t, h, owner, tt.name+".txt", []byte("v1 of "+tt.name))
+			etag := fileRequestFor(h.GetFile(), http.MethodGet, id, nil, owner).Header().Get("ETag")
+
+			rec := replaceRequest(t, h, owner, id, []byte("v2 of "+tt.name), tt.ifMatch(etag))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d %q, want %d", rec.Code, rec.Body.String(), tt.want)
+			}
+			want := tt.content + " of " + tt.name
+			if got := fileRequestFor(h.GetFile(), http.MethodGet, id, nil, owner).Body.String(); got != want {
+				t.Errorf("content = %q, want %q", got, want)
+			}
+		})
+	}
+
+	// Of two writers holding the same ETag only the first gets through
+	id := uploadFile(t, h, owner, "shared.txt", []byte("shared v1"))
+	etag := fileRequestFor(h.GetFile(), http.MethodGet, id, nil, owner).Header().Get("ETag")
+	if rec := replaceRequest(t, h, owner, id, []byte("first writer"), etag); rec.Code != http.StatusOK {
+		t.Fatalf("first writer status = %d, want 200", rec.Code)
+	}
+	if rec := replaceRequest(t, h, owner, id, []byte("second writer"), etag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("second writer status = %d, want 412", rec.Code)
+	}
+}
+
+// discardResponse is a ResponseWriter dropping the body, unlike a recorder
+// it doesn't hold the content in memory itself
+type discardResponse struct {
+	header http.Header
+	n      int64
+}
+
+func (w *discardResponse) Header() http.Header { return w.header }
+func (w *discardResponse) WriteHeader(int)     {}
+func (w *discardResponse) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// BenchmarkGetFile serves a large file, allocations per op should stay near
+// the chunk size rather than grow with the file
+func BenchmarkGetFile(b *testing.B) {
+	const size = 32 << 20
+	db := testdb.Open(b)
+	h := newTestHandlers(b, db, func(c *config.Config) {
+		c.StaleOnError = false
+		c.DownloadIdleTimeout = 0
+	})
+	owner := testOwner(b, db)
+	content := bytes.Repeat([]byte("0123456789abcdef"), size/16)
+	sf, err := h.storeFile(context.Background(), newFile{Filename: "large.bin", MimeType: "application/octet-stream", Content: content, Owner: owner})
+	if err != nil {
+		b.Fatal(err)
+	}
+	id := strconv.FormatInt(sf.ID, 10)
+	get := h.GetFile()
+
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		r := as(httptest.NewRequest(http.MethodGet, "/files/"+id, nil), owner)
+		r.SetPathValue("id", id)
+		w := &discardResponse{header: make(http.Header)}
+		get(w, r)
+		if w.n != size {
+			b.Fatalf("served %d bytes, want %d", w.n, size)
+		}
+	}
+}
+
+func TestHeadFile(t *testing.T) {
+	db := testdb.Open(t)
+	// Compressed at rest, HEAD must still report the original size
+	h := newTestHandlers(t, db, func(c *config.Config) { c.CompressAtRest = true })
+	owner := testOwner(t, db)
+	content := bytes.Repeat([]byte("compressible "), 1000)
+	id := uploadFile(t, h, owner, "head.txt", content)
+
+	tests := []struct {
+		name   string
+		id     string
+		status int
+		length string
+	}{
+		{name: "stored file", id: strconv.FormatInt(id, 10), status: http.StatusOK, length: strconv.Itoa(len(content))},
+		{name: "missing file", id: strconv.FormatInt(id+1_000_000, 10), status: http.StatusNotFound},
+		{name: "bad id", id: "x", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := as(httptest.NewRequest(http.MethodHead, "/files/"+tt.id, nil), owner)
+			r.SetPathValue("id", tt.id)
+			rec := httptest.NewRecorder()
+			h.HeadFile()(rec, r)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Content-Length"); got != tt.length {
+				t.Errorf("Content-Length = %q, want %q", got, tt.length)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("body has %d bytes, want none", rec.Body.Len())
+			}
+			if rec.Header().Get("ETag") == "" {
+				t.Error("ETag missing")
+			}
+		})
+	}
+}
+
+func TestAsyncUploadThreshold(t *testing.T) {
+	const threshold = 100
+	db := testdb.Open(t)
+	h := newTestHandlers(t, db, func(c *config.Config) { c.AsyncUploadThreshold = threshold })
+	owner := testOwner(t, db)
+
+	tests := []struct {
+		name   string
+		size   int
+		status int
+	}{
+		{name: "below", size: threshold - 1, status: http.StatusCreated},
+		{name: "at", size: threshold, status: http.StatusAccepted},
+		{name: "above", size: threshold + 1, status: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := bytes.Repeat([]byte(tt.name[:1]), tt.size)
+			rec := httptest.NewRecorder()
+			h.AddFile()(rec, as(fileRequest(t, http.MethodPost, "/add", tt.name+".bin", content), owner))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d %q, want %d", rec.Code, rec.Body.String(), tt.status)
+			}
+			if tt.status != http.StatusAccepted {
+				return
+			}
+
+			loc := rec.Header().Get("Location")
+			jobID, ok := strings.CutPrefix(loc, "/jobs/")
+			if !ok {
+				t.Fatalf("Location = %q, want /jobs/{id}", loc)
+			}
+			if err := h.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, loc, nil)
+			r.SetPathValue("id", jobID)
+			rec = httptest.NewRecorder()
+			h.GetJob()(rec, r)
+			var j job
+			if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil {
+				t.Fatal(err)
+			}
+			if j.Status != JobDone || j.FileID == 0 {
+				t.Fatalf("job = %+v, want done with a file id", j)
+			}
+			if got := fileRequestFor(h.GetFile(), http.MethodGet, j.FileID, nil, owner).Body.Bytes(); !bytes.Equal(got, content) {
+				t.Errorf("stored %d bytes, want %d", len(got), len(content))
+			}
+		})
+	}
+}
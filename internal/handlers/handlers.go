@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"inv/internal/config"
+	"inv/internal/dberr"
+	"inv/internal/encryption"
+	"inv/internal/logging"
+	"inv/internal/membudget"
+	"inv/internal/middlewares"
+	"inv/internal/storage"
+)
+
+// Handlers holds the dependencies shared by the HTTP handlers.
+type Handlers struct {
+	db     *sql.DB
+	store  storage.Storage
+	logger logging.Logger
+	cfg    config.Config
+
+	downloads *keyedLimiter
+	memory    *membudget.Budget
+	listeners []func(FileEvent)
+	stale     *staleCache // nil unless StaleOnError is enabled
+	jobs      *jobRegistry
+	cipher    *encryption.Cipher // nil unless an encryption key is configured
+	stats     statsCache
+	proxies   middlewares.TrustedProxies
+	events    *broker      // nil unless EventsEnabled
+	fetcher   *http.Client // nil unless FetchEnabled
+	spool     *spool       // nil unless SpoolDir is set
+
+	// Size caps by media type or "type/*", see Config.MimeSizeLimits
+	mimeLimits map[string]int64
+
+	// ALLOWED_EXTENSIONS lowercased with a leading dot
+	extensions []string
+
+	// Prepared statements
+	insertFileStmt   *sql.Stmt
+	findDupStmt      *sql.Stmt
+	getFileStmt      *sql.Stmt
+	transferFileStmt *sql.Stmt
+	copyFileStmt     *sql.Stmt
+	deleteFileStmt   *sql.Stmt
+	restoreFileStmt  *sql.Stmt
+	patchTagsStmt    *sql.Stmt
+	replaceFileStmt  *sql.Stmt
+	getThumbStmt     *sql.Stmt
+	putThumbStmt     *sql.Stmt
+	auditStmt        *sql.Stmt
+	fileMetaStmt     *sql.Stmt
+}
+
+// New prepares the statements used by the handlers.
+func New(db *sql.DB, store storage.Storage, logger logging.Logger, cfg config.Config) (*Handlers, error) {
+	h := &Handlers{
+		db:        db,
+		store:     store,
+		logger:    logger,
+		cfg:       cfg,
+		downloads: newKeyedLimiter(cfg.MaxDownloadsPerFile),
+		memory:    membudget.New(cfg.MemoryBudgetBytes),
+		jobs:      newJobRegistry(),
+	}
+	if cfg.StaleOnError {
+		h.stale = newStaleCache(cfg.StaleCacheBytes)
+	}
+	if cfg.FetchEnabled {
+		h.fetcher = newFetchClient(cfg.FetchTimeout)
+	}
+	if cfg.SpoolDir != "" {
+		var err error
+		if h.spool, err = newSpool(cfg.SpoolDir); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.EventsEnabled {
+		h.events = newBroker(cfg.MaxEventSubscribers)
+		h.OnFileEvent(h.events.publish)
+	}
+	var err error
+	if h.proxies, err = cfg.TrustedProxyPrefixes(); err != nil {
+		return nil, fmt.Errorf("parse trusted proxies: %w", err)
+	}
+	h.extensions = normalizeExtensions(cfg.AllowedExtensions)
+	if h.mimeLimits, err = cfg.MimeSizeLimitsMap(); err != nil {
+		return nil, fmt.Errorf("parse mime size limits: %w", err)
+	}
+	key, err := cfg.EncryptionKeyBytes()
+	if err != nil {
+		return nil, fmt.Errorf("decode encryption key: %w", err)
+	}
+	if key != nil {
+		if h.cipher, err = encryption.New(key); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range h.statements() {
+		stmt, err := db.Prepare(s.query)
+		if err != nil {
+			h.Close()
+			return nil, fmt.Errorf("prepare %s statement: %w", s.name, err)
+		}
+		*s.dst = stmt
+	}
+	return h, nil
+}
+
+// notExpired is the condition hiding files past their expires_at until the
+// sweeper deletes them
+const notExpired = `(expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)`
+
+// ownedBy is the condition limiting a query to the files of the owner bound
+// to parameter n, every file when it's NULL. See readScope.
+func ownedBy(n int) string {
+	return fmt.Sprintf("($%d::text IS NULL OR owner_id = $%d)", n, n)
+}
+
+// statement describes a prepared statement owned by Handlers
+type statement struct {
+	dst   **sql.Stmt
+	name  string
+	query string
+}
+
+// statements lists the prepared statements, New prepares them in order
+func (h *Handlers) statements() []statement {
+	return []statement{
+		{&h.insertFileStmt, "insert", `
+        INSERT INTO files (filename, mime_type, size, checksum, owner_id, metadata, compressed, storage_key, dedup_key, expires_at, encrypted)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP + $10::float8 * INTERVAL '1 second', $11)
+        RETURNING id, created_at`},
+		{&h.findDupStmt, "find duplicate", `
+        SELECT id, size, checksum, created_at
+        FROM files
+        WHERE owner_id = $1 AND dedup_key = $2 AND deleted_at IS NULL`},
+		{&h.getFileStmt, "get", `
+        SELECT filename, mime_type, metadata, compressed, storage_key, size, checksum, encrypted, owner_id
+        FROM files
+        WHERE id = $1 AND deleted_at IS NULL AND ` + notExpired + ` AND ` + ownedBy(2)},
+		{&h.transferFileStmt, "transfer", `
+        UPDATE files SET owner_id = $2
+        WHERE id = $1 AND deleted_at IS NULL
+        RETURNING id`},
+		{&h.copyFileStmt, "copy", `
+        WITH src AS (
+            SELECT * FROM files WHERE id = $1 AND deleted_at IS NULL AND ` + notExpired + `
+        )
+        INSERT INTO files (filename, mime_type, size, checksum, owner_id, metadata, compressed, storage_key, dedup_key, expires_at, encrypted)
+        SELECT filename, mime_type, size, checksum, $2, metadata, compressed, $3, dedup_key, expires_at, encrypted
+        FROM src
+        RETURNING id, (SELECT storage_key FROM src)`},
+		{&h.deleteFileStmt, "delete", `
+        UPDATE files SET deleted_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND deleted_at IS NULL AND ` + ownedBy(2)},
+		{&h.restoreFileStmt, "restore", `
+        UPDATE files SET deleted_at = NULL
+        WHERE id = $1 AND deleted_at IS NOT NULL AND ` + ownedBy(2)},
+		{&h.patchTagsStmt, "patch tags", `
+        UPDATE files SET tags = (
+            SELECT COALESCE(jsonb_agg(t ORDER BY t), '[]'::jsonb)
+            FROM (
+                SELECT jsonb_array_elements_text(tags) AS t
+                UNION
+                SELECT jsonb_array_elements_text($2::jsonb)
+                EXCEPT
+                SELECT jsonb_array_elements_text($3::jsonb)
+            ) merged
+        )
+        WHERE id = $1 AND deleted_at IS NULL AND ` + ownedBy(4) + `
+        RETURNING tags`},
+		{&h.replaceFileStmt, "replace", `
+        UPDATE files
+        SET size = $2, mime_type = $3, checksum = $4, compressed = $5, encrypted = $6, storage_key = $7,
+            dedup_key = NULL, corrupt = FALSE, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND deleted_at IS NULL
+        RETURNING updated_at`},
+		{&h.getThumbStmt, "get thumbnail", `
+        SELECT content FROM thumbnails WHERE file_id = $1 AND width = $2`},
+		{&h.putThumbStmt, "put thumbnail", `
+        INSERT INTO thumbnails (file_id, width, content)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (file_id, width) DO NOTHING`},
+		{&h.auditStmt, "audit", `
+        INSERT INTO audit_log (action, file_id, actor, remote_addr)
+        VALUES ($1, $2, $3, $4)`},
+		{&h.fileMetaStmt, "file meta", `
+        SELECT id, filename, mime_type, size, checksum, created_at
+        FROM files
+        WHERE id = $1 AND deleted_at IS NULL AND ` + notExpired + ` AND ` + ownedBy(2)},
+	}
+}
+
+// Close releases the prepared statements.
+func (h *Handlers) Close() error {
+	var errs []error
+	for _, s := range h.statements() {
+		if *s.dst == nil {
+			continue
+		}
+		if err := (*s.dst).Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeJSON encodes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// classifyDBError returns the status for a failed database operation, 503
+// when the database is saturated or unreachable so clients retry later
+func classifyDBError(err error) int {
+	if dberr.IsUnavailable(err) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writeDBError answers a failed operation with the status from
+// classifyDBError, msg describes the failure otherwise. Content too large
+// for the database gets 413 with the largest size it can hold.
+func writeDBError(w http.ResponseWriter, err error, msg string) {
+	if dberr.IsTooLarge(err) {
+		writeTooLarge(w, storage.MaxDBContentBytes, 0)
+		return
+	}
+	status := classifyDBError(err)
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+		msg = "Database is unavailable, retry later"
+	}
+	http.Error(w, msg, status)
+}
+
+// writeError sends msg as a JSON error body
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// readScope returns the owner whose files the caller of ctx may see and
+// change. It's NULL, meaning every file, for admins and for requests without
+// a principal, which only get this far with auth exempted, e.g. through a
+// signed download URL.
+func readScope(ctx context.Context) sql.NullString {
+	p, ok := middlewares.PrincipalFrom(ctx)
+	if !ok || p.HasScope(middlewares.ScopeAdmin) {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: p.Subject, Valid: true}
+}
+
+// log returns the request-scoped logger from ctx, h.logger outside requests
+func (h *Handlers) log(ctx context.Context) logging.Logger {
+	if l, ok := middlewares.LoggerFrom(ctx); ok {
+		return l
+	}
+	return h.logger
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"inv/internal/config"
+	"inv/internal/dberr"
+	"inv/internal/logging"
+	"inv/internal/middlewares"
+	"inv/internal/storage"
+	"inv/internal/testdb"
+)
+
+func TestWriteDBError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		retryAfter bool
+	}{
+		{name: "generic", err: errors.New("syntax error"), status: http.StatusInternalServerError},
+		{name: "pool exhausted", err: fmt.Errorf("query: %w", context.DeadlineExceeded), status: http.StatusServiceUnavailable, retryAfter: true},
+		{name: "too many clients", err: &pq.Error{Code: dberr.TooManyClients}, status: http.StatusServiceUnavailable, retryAfter: true},
+		{name: "value too large", err: &pq.Error{Code: dberr.ProgramLimit}, status: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeDBError(rec, tt.err, "Failed")
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Retry-After") != ""; got != tt.retryAfter {
+				t.Errorf("Retry-After set = %v, want %v", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestWriteTooLarge(t *testing.T) {
+	tests := []struct {
+		name      string
+		attempted int64
+		want      map[string]any
+	}{
+		{name: "unknown size", want: map[string]any{"limit_bytes": 100.0}},
+		{name: "declared size", attempted: 150, want: map[string]any{"limit_bytes": 100.0, "attempted_bytes": 150.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeTooLarge(rec, 100, tt.attempted)
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("status = %d, want 413", rec.Code)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["error"] == "" || len(body) != len(tt.want)+1 {
+				t.Errorf("body = %v", body)
+			}
+			for k, v := range tt.want {
+				if body[k] != v {
+					t.Errorf("%s = %v, want %v", k, body[k], v)
+				}
+			}
+		})
+	}
+}
+
+// newTestHandlers returns Handlers on db with the default config, after
+// change edits it
+func newTestHandlers(tb testing.TB, db *sql.DB, change func(*config.Config)) *Handlers {
+	tb.Helper()
+	cfg := config.LoadConfig(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	if change != nil {
+		change(&cfg)
+	}
+	h, err := New(db, storage.NewDB(db), logging.Discard, cfg)
+	if err != nil {
+		tb.Fatalf("New: %v", err)
+	}
+	tb.Cleanup(func() { h.Close() })
+	return h
+}
+
+var ownerSeq atomic.Int64
+
+// testOwner returns an owner id unique to this run, whose files, keys and
+// upload sessions are deleted when tb ends
+func testOwner(tb testing.TB, db *sql.DB) string {
+	tb.Helper()
+	owner := fmt.Sprintf("test-%d-%d", time.Now().UnixNano(), ownerSeq.Add(1))
+	tb.Cleanup(func() {
+		for _, table := range []string{"files", "idempotency_keys", "upload_sessions"} {
+			if _, err := db.Exec(`DELETE FROM `+table+` WHERE owner_id = $1`, owner); err != nil {
+				tb.Errorf("clean up %s: %v", table, err)
+			}
+		}
+	})
+	return owner
+}
+
+// as returns r made by subject with scopes
+func as(r *http.Request, subject string, scopes ...string) *http.Request {
+	p := middlewares.Principal{Subject: subject, Scopes: scopes}
+	return r.WithContext(middlewares.WithPrincipal(r.Context(), p))
+}
+
+// fileRequest returns a multipart request uploading content as filename
+func fileRequest(tb testing.TB, method, target, filename string, content []byte) *http.Request {
+	tb.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	part.Write(content)
+	mw.Close()
+	r := httptest.NewRequest(method, target, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+// responseID returns the file id ending an upload response
+func responseID(tb testing.TB, rec *httptest.ResponseRecorder) int64 {
+	tb.Helper()
+	body := rec.Body.String()
+	id, err := strconv.ParseInt(body[strings.LastIndex(body, " ")+1:], 10, 64)
+	if err != nil {
+		tb.Fatalf("no file id in response %d %q", rec.Code, body)
+	}
+	return id
+}
+
+// uploadFile stores content as filename for owner through AddFile,
+// returning the file id
+func uploadFile(tb testing.TB, h *Handlers, owner, filename string, content []byte) int64 {
+	tb.Helper()
+	rec := httptest.NewRecorder()
+	h.AddFile()(rec, as(fileRequest(tb, http.MethodPost, "/add", filename, content), owner))
+	if rec.Code != http.StatusCreated && rec.Code != http.StatusOK {
+		tb.Fatalf("upload %s: status %d %q", filename, rec.Code, rec.Body.String())
+	}
+	return responseID(tb, rec)
+}
+
+// fileRequestFor runs handler for file id as subject
+func fileRequestFor(handler http.HandlerFunc, method string, id int64, body io.Reader, subject string, scopes ...string) *httptest.ResponseRecorder {
+	target := "/files/" + strconv.FormatInt(id, 10)
+	r := as(httptest.NewRequest(method, target, body), subject, scopes...)
+	r.SetPathValue("id", strconv.FormatInt(id, 10))
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
+
+func TestDrivers(t *testing.T) {
+	content := []byte("the same bytes through every driver \x00\xff")
+	for _, driver := range []string{config.DBDriverPQ, config.DBDriverPGX} {
+		t.Run(driver, func(t *testing.T) {
+			db := testdb.OpenDriver(t, driver)
+			h := newTestHandlers(t, db, nil)
+			owner := testOwner(t, db)
+
+			id := uploadFile(t, h, owner, "driver.bin", content)
+			rec := fileRequestFor(h.GetFile(), http.MethodGet, id, nil, owner)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET status = %d, want 200", rec.Code)
+			}
+			if !bytes.Equal(rec.Body.Bytes(), content) {
+				t.Errorf("GET body = %q, want %q", rec.Body.Bytes(), content)
+			}
+
+			if rec := fileRequestFor(h.DeleteFile(), http.MethodDelete, id, nil, owner); rec.Code != http.StatusNoContent {
+				t.Fatalf("DELETE status = %d, want 204", rec.Code)
+			}
+			if rec := fileRequestFor(h.GetFile(), http.MethodGet, id, nil, owner); rec.Code != http.StatusNotFound {
+				t.Errorf("GET after delete status = %d, want 404", rec.Code)
+			}
+		})
+	}
+}
+
+func TestReadScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *middlewares.Principal
+		want      string
+		valid     bool
+	}{
+		{name: "no principal"},
+		{name: "admin", principal: &middlewares.Principal{Subject: "root", Scopes: []string{middlewares.ScopeAdmin}}},
+		{name: "user", principal: &middlewares.Principal{Subject: "u1", Scopes: []string{"files:read"}}, want: "u1", valid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = middlewares.WithPrincipal(ctx, *tt.principal)
+			}
+			got := readScope(ctx)
+			if got.Valid != tt.valid || got.String != tt.want {
+				t.Errorf("readScope = %+v, want %q valid %v", got, tt.want, tt.valid)
+			}
+		})
+	}
+}
+
+func TestOwnerScoping(t *testing.T) {
+	db := testdb.Open(t)
+	h := newTestHandlers(t, db, nil)
+	owner, other := testOwner(t, db), testOwner(t, db)
+	id := uploadFile(t, h, owner, "mine.txt", []byte("only for the owner"))
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		subject string
+		scopes  []string
+		want    int
+	}{
+		{name: "owner reads", handler: h.GetFile(), method: http.MethodGet, subject: owner, want: http.StatusOK},
+		{name: "other user reads", handler: h.GetFile(), method: http.MethodGet, subject: other, want: http.StatusNotFound},
+		{name: "admin reads", handler: h.GetFile(), method: http.MethodGet, subject: "root", scopes: []string{middlewares.ScopeAdmin}, want: http.StatusOK},
+		{name: "other user meta", handler: h.FileMeta(), method: http.MethodGet, subject: other, want: http.StatusNotFound},
+		{name: "owner meta", handler: h.FileMeta(), method: http.MethodGet, subject: owner, want: http.StatusOK},
+		{name: "other user deletes", handler: h.DeleteFile(), method: http.MethodDelete, subject: other, want: http.StatusNotFound},
+		{name: "owner deletes", handler: h.DeleteFile(), method: http.MethodDelete, subject: owner, want: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := fileRequestFor(tt.handler, tt.method, id, nil, tt.subject, tt.scopes...)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferFile(t *testing.T) {
+	db := testdb.Open(t)
+	h := newTestHandlers(t, db, nil)
+
+	tests := []struct {
+		name        string
+		copy        bool
+		wantOldSees int
+	}{
+		{name: "move", wantOldSees: http.StatusNotFound},
+		{name: "copy", copy: true, wantOldSees: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := testOwner(t, db), testOwner(t, db)
+			content := []byte("transferred by " + tt.name)
+			id := uploadFile(t, h, from, "transfer.txt", content)
+
+			target := "/files/" + strconv.FormatInt(id, 10) + "/transfer"
+			if tt.copy {
+				target += "?copy=true"
+			}
+			r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"owner_id":"`+to+`"}`))
+			r.SetPathValue("id", strconv.FormatInt(id, 10))
+			rec := httptest.NewRecorder()
+			h.TransferFile()(rec, as(r, "root", middlewares.ScopeAdmin))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("transfer status = %d %q, want 200", rec.Code, rec.Body.String())
+			}
+			var resp struct {
+				ID      int64  `json:"id"`
+				OwnerID string `json:"owner_id"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.OwnerID != to {
+				t.Errorf("owner_id = %q, want %q", resp.OwnerID, to)
+			}
+			if tt.copy == (resp.ID == id) {
+				t.Errorf("id = %d for original %d, copy %v", resp.ID, id, tt.copy)
+			}
+
+			rec = fileRequestFor(h.GetFile(), http.MethodGet, resp.ID, nil, to)
+			if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), content) {
+				t.Errorf("new owner GET = %d %q, want 200 %q", rec.Code, rec.Body.Bytes(), content)
+			}
+			if rec := fileRequestFor(h.GetFile(), http.MethodGet, id, nil, from); rec.Code != tt.wantOldSees {
+				t.Errorf("old owner GET status = %d, want %d", rec.Code, tt.wantOldSees)
+			}
+		})
+	}
+}
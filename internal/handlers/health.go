@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// Healthz reports whether the server can reach the database
+func (h *Handlers) Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h.db.PingContext(r.Context()); err != nil {
+			h.log(r.Context()).Warn(r.Context(), "Failed health check", slog.String("error", err.Error()))
+			writeDBError(w, err, "Database is unavailable")
+			return
+		}
+		w.Write([]byte("ok\n"))
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"inv/internal/middlewares"
+)
+
+// maxIdempotencyKeyLength matches the key column
+const maxIdempotencyKeyLength = 255
+
+// saveIdempotent is saveUpload for requests carrying an Idempotency-Key. The
+// first request with a key claims it by inserting its row, the primary key
+// makes concurrent requests with the same key lose that race. Once the
+// upload is answered the response is recorded on the row and replayed to
+// retries until IdempotencyKeyTTL passes. Retries arriving while the first
+// request is still running get 409, a key reused for a different upload 422.
+func (h *Handlers) saveIdempotent(w http.ResponseWriter, r *http.Request, f newFile, key string) {
+	if len(key) > maxIdempotencyKeyLength {
+		writeError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+		return
+	}
+	owner, _ := middlewares.PrincipalFrom(r.Context())
+	fingerprint := uploadFingerprint(f)
+
+	claimed, err := h.claimIdempotencyKey(r.Context(), owner.Subject, key, fingerprint)
+	if err != nil {
+		h.log(r.Context()).Error(r.Context(), "Failed to claim idempotency key", slog.String("error", err.Error()))
+		writeDBError(w, err, "Failed to save file to database")
+		return
+	}
+	if !claimed {
+		h.replayIdempotent(w, r, owner.Subject, key, fingerprint)
+		return
+	}
+
+	rec := &recordingWriter{ResponseWriter: w}
+	id := h.storeUpload(rec, r, f)
+
+	// Record the outcome even if the client went away meanwhile, its retry
+	// is what the key is for
+	ctx := context.WithoutCancel(r.Context())
+	if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+		// Failures aren't remembered so the retry can succeed
+		_, err = h.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE owner_id = $1 AND key = $2`, owner.Subject, key)
+	} else {
+		var fileID *int64
+		if id != 0 {
+			fileID = &id
+		}
+		_, err = h.db.ExecContext(ctx, `
+            UPDATE idempotency_keys SET status = $3, location = $4, body = $5, file_id = $6
+            WHERE owner_id = $1 AND key = $2`,
+			owner.Subject, key, rec.status, rec.Header().Get("Location"), rec.body.String(), fileID)
+	}
+	if err != nil {
+		h.log(ctx).Error(ctx, "Failed to record idempotency key", slog.String("error", err.Error()))
+	}
+}
+
+// claimIdempotencyKey inserts the row for key, reporting false when another
+// request holds it. Expired rows are taken over, as are rows of requests
+// that haven't finished within an hour, their process likely died.
+func (h *Handlers) claimIdempotencyKey(ctx context.Context, owner, key, fingerprint string) (bool, error) {
+	var claimed bool
+	err := h.db.QueryRowContext(ctx, `
+        INSERT INTO idempotency_keys (owner_id, key, fingerprint, expires_at)
+        VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4::float8 * INTERVAL '1 second')
+        ON CONFLICT (owner_id, key) DO UPDATE
+        SET fingerprint = EXCLUDED.fingerprint, status = NULL, location = NULL, body = NULL, file_id = NULL,
+            created_at = CURRENT_TIMESTAMP, expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+            OR (idempotency_keys.status IS NULL AND idempotency_keys.created_at < CURRENT_TIMESTAMP - INTERVAL '1 hour')
+        RETURNING TRUE`,
+		owner, key, fingerprint, h.cfg.IdempotencyKeyTTL.Seconds()).Scan(&claimed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return claimed, err
+}
+
+// replayIdempotent answers a request whose key was claimed before
+func (h *Handlers) replayIdempotent(w http.ResponseWriter, r *http.Request, owner, key, fingerprint string) {
+	var (
+		stored   string
+		status   sql.NullInt64
+		location sql.NullString
+		body     sql.NullString
+	)
+	err := h.db.QueryRowContext(r.Context(), `
+        SELECT fingerprint, status, location, body
+        FROM idempotency_keys
+        WHERE owner_id = $1 AND key = $2`, owner, key).Scan(&stored, &status, &location, &body)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The first request failed and released the key meanwhile
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusConflict, "A request with this Idempotency-Key is in progress, retry later")
+		return
+	}
+	if err != nil {
+		h.log(r.Context()).Error(r.Context(), "Failed to load idempotency key", slog.String("error", err.Error()))
+		writeDBError(w, err, "Failed to save file to database")
+		return
+	}
+
+	switch {
+	case stored != fingerprint:
+		writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different upload")
+	case !status.Valid:
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusConflict, "A request with this Idempotency-Key is in progress, retry later")
+	default:
+		if location.String != "" {
+			w.Header().Set("Location", location.String)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(int(status.Int64))
+		w.Write([]byte(body.String))
+	}
+}
+
+// uploadFingerprint identifies an upload by its name and content, so a key
+// can't be replayed for a different file
+func uploadFingerprint(f newFile) string {
+	hash := sha256.New()
+	hash.Write([]byte(f.Filename))
+	hash.Write([]byte{0})
+	hash.Write(f.Content)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter passes a response through while keeping its status and
+// body, upload responses are short
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"inv/internal/config"
+	"inv/internal/testdb"
+)
+
+func TestUploadFingerprint(t *testing.T) {
+	base := newFile{Filename: "a.txt", Content: []byte("hello")}
+	tests := []struct {
+		name string
+		f    newFile
+		same bool
+	}{
+		{name: "identical", f: newFile{Filename: "a.txt", Content: []byte("hello")}, same: true},
+		{name: "other owner and type", f: newFile{Filename: "a.txt", Content: []byte("hello"), Owner: "x", MimeType: "text/html"}, same: true},
+		{name: "other name", f: newFile{Filename: "b.txt", Content: []byte("hello")}},
+		{name: "other content", f: newFile{Filename: "a.txt", Content: []byte("hellO")}},
+		{name: "name and content boundary", f: newFile{Filename: "a.txth", Content: []byte("ello")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uploadFingerprint(tt.f) == uploadFingerprint(base); got != tt.same {
+				t.Errorf("same fingerprint = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+// idempotentUpload uploads content as filename for owner with key
+func idempotentUpload(t *testing.T, h *Handlers, owner, key, filename string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	r := fileRequest(t, http.MethodPost, "/add", filename, content)
+	r.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	h.AddFile()(rec, as(r, owner))
+	return rec
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	db := testdb.Open(t)
+	h := newTestHandlers(t, db, nil)
+	owner := testOwner(t, db)
+
+	first := idempotentUpload(t, h, owner, "k1", "a.txt", []byte("first"))
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first status = %d %q, want 201", first.Code, first.Body.String())
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		filename string
+		content  string
+		want     int
+		replayed bool
+	}{
+		{name: "retry", key: "k1", filename: "a.txt", content: "first", want: http.StatusCreated, replayed: true},
+		{name: "key reused for other content", key: "k1", filename: "a.txt", content: "second", want: http.StatusUnprocessableEntity},
+		{name: "key reused for other name", key: "k1", filename: "b.txt", content: "first", want: http.StatusUnprocessableEntity},
+		{name: "new key", key: "k2", filename: "c.txt", content: "third", want: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := idempotentUpload(t, h, owner, tt.key, tt.filename, []byte(tt.content))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d %q, want %d", rec.Code, rec.Body.String(), tt.want)
+			}
+			if replayed := rec.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.replayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.replayed)
+			}
+			if tt.replayed && rec.Body.String() != first.Body.String() {
+				t.Errorf("body = %q, want the first response %q", rec.Body.String(), first.Body.String())
+			}
+		})
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM files WHERE owner_id = $1 AND filename = 'a.txt'`, owner).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%d rows for a.txt, want 1", n)
+	}
+}
+
+func TestIdempotentConcurrent(t *testing.T) {
+	db := testdb.Open(t)
+	// Dedup would hide a second insert, the key alone has to prevent it
+	h := newTestHandlers(t, db, func(c *config.Config) { c.DedupUploads = false })
+	owner := testOwner(t, db)
+
+	const n = 8
+	recs := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := range recs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = idempotentUpload(t, h, owner, "same", "race.txt", []byte("raced"))
+		}()
+	}
+	wg.Wait()
+
+	var created int
+	for _, rec := range recs {
+		switch {
+		case rec.Code == http.StatusCreated && rec.Header().Get("Idempotent-Replayed") == "":
+			created++
+		case rec.Code == http.StatusCreated, rec.Code == http.StatusConflict:
+		default:
+			t.Errorf("status = %d %q, want 201 or 409", rec.Code, rec.Body.String())
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d uploads stored, want 1", created)
+	}
+	var rows int
+	if err := db.QueryRow(`SELECT count(*) FROM files WHERE owner_id = $1`, owner).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("%d rows, want 1", rows)
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	JobPending = "pending"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// jobRetention is how long finished jobs stay queryable
+const jobRetention = time.Hour
+
+// job tracks an upload stored in the background
+type job struct {
+	ID       string    `json:"id"`
+	Status   string    `json:"status"`
+	FileID   int64     `json:"file_id,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Finished time.Time `json:"-"`
+}
+
+// jobRegistry keeps the state of background uploads in memory
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	wg   sync.WaitGroup
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*job)}
+}
+
+// start runs fn in the background under a new job and returns the job id
+func (r *jobRegistry) start(fn func() (int64, error)) (string, error) {
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+	r.add(id)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		fileID, err := fn()
+		r.finish(id, fileID, err)
+	}()
+	return id, nil
+}
+
+// add registers a pending job under id unless it's known already
+func (r *jobRegistry) add(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruneLocked()
+	if _, ok := r.jobs[id]; !ok {
+		r.jobs[id] = &job{ID: id, Status: JobPending}
+	}
+}
+
+// finish records the outcome of job id
+func (r *jobRegistry) finish(id string, fileID int64, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, ok := r.jobs[id]
+	if !ok {
+		return
+	}
+	j.Finished = time.Now()
+	if err != nil {
+		j.Status, j.Error = JobFailed, err.Error()
+		return
+	}
+	j.Status, j.FileID = JobDone, fileID
+}
+
+// randomID returns a random 16 character hex id
+func randomID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// get returns a snapshot of the job
+func (r *jobRegistry) get(id string) (job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, ok := r.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// pruneLocked forgets jobs finished more than jobRetention ago
+func (r *jobRegistry) pruneLocked() {
+	for id, j := range r.jobs {
+		if !j.Finished.IsZero() && time.Since(j.Finished) > jobRetention {
+			delete(r.jobs, id)
+		}
+	}
+}
+
+// Wait blocks until background uploads finish or ctx is done
+func (h *Handlers) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.jobs.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// storeAsync stores f in the background, detached from the request context,
+// and records the upload as audit once the file id is known
+func (h *Handlers) storeAsync(ctx context.Context, f newFile, audit auditEntry) (string, error) {
+	ctx = context.WithoutCancel(ctx)
+	return h.jobs.start(func() (int64, error) {
+		stored, err := h.storeFile(ctx, f)
+		if err != nil {
+			h.log(ctx).Error(ctx, "Failed to save file in background", slog.String("error", err.Error()))
+			return 0, err
+		}
+		audit.FileID = stored.ID
+		h.audit(ctx, audit)
+		return stored.ID, nil
+	})
+}
+
+// GetJob reports the status of the background upload identified by the {id} path value
+func (h *Handlers) GetJob() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		j, ok := h.jobs.get(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, j)
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// rawJSON scans a json/jsonb column and encodes it verbatim
+type rawJSON json.RawMessage
+
+// Scan implements sql.Scanner, drivers return json as either bytes or string
+func (j *rawJSON) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = rawJSON(v)
+	case nil:
+		*j = nil
+	default:
+		return fmt.Errorf("cannot scan %T into json", src)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (j rawJSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// parseMetadata validates that raw is a JSON object and returns it compacted,
+// an empty value yields an empty object
+func parseMetadata(raw string) (string, error) {
+	if raw == "" {
+		return "{}", nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(raw), &obj); err != nil || obj == nil {
+		return "", fmt.Errorf("metadata must be a JSON object")
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import "testing"
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "", want: "{}"},
+		{raw: "{}", want: "{}"},
+		{raw: `{ "b": 1, "a": "x" }`, want: `{"a":"x","b":1}`},
+		{raw: `{"nested": {"k": [1, 2]}}`, want: `{"nested":{"k":[1,2]}}`},
+		{raw: "null", wantErr: true},
+		{raw: "[]", wantErr: true},
+		{raw: `"text"`, wantErr: true},
+		{raw: "{", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseMetadata(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseMetadata(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRawJSONMarshal(t *testing.T) {
+	for _, tt := range []struct {
+		in   rawJSON
+		want string
+	}{
+		{nil, "null"},
+		{rawJSON(`{"a":1}`), `{"a":1}`},
+	} {
+		got, err := tt.in.MarshalJSON()
+		if err != nil || string(got) != tt.want {
+			t.Errorf("MarshalJSON(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
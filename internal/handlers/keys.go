@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"inv/internal/apikeys"
+)
+
+// ListKeys returns every API key with its usage as a JSON array
+func (h *Handlers) ListKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := apikeys.List(r.Context(), h.db)
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to list api keys", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to list api keys")
+			return
+		}
+		encodeResponse(w, r, http.StatusOK, keys)
+	}
+}
+
+// RevokeKey revokes the API key identified by the {id} path value, requests
+// using it are rejected from then on
+func (h *Handlers) RevokeKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid key id", http.StatusBadRequest)
+			return
+		}
+
+		err = apikeys.Revoke(r.Context(), h.db, id)
+		if errors.Is(err, apikeys.ErrNotFound) {
+			http.Error(w, "Key not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to revoke api key", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to revoke api key")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
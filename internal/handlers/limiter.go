@@ -0,0 +1,41 @@
+package handlers
+
+import "sync"
+
+// keyedLimiter bounds the number of concurrent holders per key, a zero max
+// means unlimited
+type keyedLimiter struct {
+	mu     sync.Mutex
+	max    int
+	active map[int64]int
+}
+
+func newKeyedLimiter(max int) *keyedLimiter {
+	return &keyedLimiter{max: max, active: make(map[int64]int)}
+}
+
+// acquire reserves a slot for key, reporting false when the key is at capacity
+func (l *keyedLimiter) acquire(key int64) bool {
+	if l.max <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[key] >= l.max {
+		return false
+	}
+	l.active[key]++
+	return true
+}
+
+// release frees a slot acquired for key
+func (l *keyedLimiter) release(key int64) {
+	if l.max <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[key]--; l.active[key] <= 0 {
+		delete(l.active, key)
+	}
+}
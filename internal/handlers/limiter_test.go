@@ -0,0 +1,48 @@
+package handlers
+
+import "testing"
+
+func TestKeyedLimiter(t *testing.T) {
+	type step struct {
+		release bool
+		key     int64
+		want    bool // for acquires
+	}
+	tests := []struct {
+		name  string
+		max   int
+		steps []step
+	}{
+		{name: "unlimited", max: 0, steps: []step{{key: 1, want: true}, {key: 1, want: true}, {key: 1, want: true}}},
+		{name: "at capacity", max: 2, steps: []step{{key: 1, want: true}, {key: 1, want: true}, {key: 1, want: false}}},
+		{name: "per key", max: 1, steps: []step{{key: 1, want: true}, {key: 2, want: true}, {key: 1, want: false}}},
+		{name: "released", max: 1, steps: []step{{key: 1, want: true}, {release: true, key: 1}, {key: 1, want: true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newKeyedLimiter(tt.max)
+			for i, s := range tt.steps {
+				if s.release {
+					l.release(s.key)
+					continue
+				}
+				if got := l.acquire(s.key); got != s.want {
+					t.Fatalf("step %d: acquire(%d) = %v, want %v", i, s.key, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyedLimiterForgetsIdleKeys(t *testing.T) {
+	l := newKeyedLimiter(2)
+	l.acquire(1)
+	l.acquire(1)
+	l.acquire(2)
+	l.release(1)
+	l.release(1)
+	l.release(2)
+	if len(l.active) != 0 {
+		t.Errorf("active = %v after releasing everything, want empty", l.active)
+	}
+}
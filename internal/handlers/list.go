@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
+// listFields maps the fields selectable with ?fields= to scan destinations.
+// Keys double as column names, so only allowlisted names ever reach the SQL.
+var listFields = map[string]func() any{
+	"id":         func() any { return new(int64) },
+	"filename":   func() any { return new(string) },
+	"mime_type":  func() any { return new(string) },
+	"size":       func() any { return new(int64) },
+	"checksum":   func() any { return new(*string) },
+	"owner_id":   func() any { return new(string) },
+	"created_at": func() any { return new(*time.Time) },
+	"metadata":   func() any { return new(rawJSON) },
+	"tags":       func() any { return new(rawJSON) },
+}
+
+// allListFields is the default field set, in response order
+var allListFields = []string{"id", "filename", "mime_type", "size", "checksum", "owner_id", "created_at", "metadata", "tags"}
+
+// listPage is the response of ListFiles. Total counts every file matching
+// the filters and is left out when paging by cursor, which avoids the count.
+type listPage struct {
+	Items      []map[string]any `json:"items"`
+	Total      *int64           `json:"total,omitempty"`
+	Limit      int              `json:"limit"`
+	Offset     int              `json:"offset"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// ListFiles returns a page of file metadata, oldest first, paginated with
+// ?limit= and either ?offset= or ?cursor=, the next_cursor of the previous
+// page. ?fields=id,filename selects a subset of fields, ?q= keeps files whose
+// name contains q case-insensitively and ?meta.<key>=<value> keeps files whose
+// metadata has that key/value.
+func (h *Handlers) ListFiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		fields, err := parseFields(query.Get("fields"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit, offset, err := parsePage(query.Get("limit"), query.Get("offset"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var after *listCursor
+		if raw := query.Get("cursor"); raw != "" {
+			if query.Has("offset") {
+				http.Error(w, "cursor and offset can't be combined", http.StatusBadRequest)
+				return
+			}
+			c, err := parseCursor(raw)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			after = &c
+		}
+
+		page := listPage{Items: []map[string]any{}, Limit: limit, Offset: offset}
+		where, args := listFilters(query, readScope(r.Context()))
+		if after == nil {
+			var total int64
+			if err := h.db.QueryRowContext(r.Context(), `SELECT COUNT(*) FROM files `+where, args...).Scan(&total); err != nil {
+				h.log(r.Context()).Error(r.Context(), "Failed to count files", slog.String("error", err.Error()))
+				writeDBError(w, err, "Failed to list files")
+				return
+			}
+			page.Total = &total
+		} else {
+			args = append(args, after.createdAt, after.id)
+			where += fmt.Sprintf(" AND (created_at, id) > ($%d::timestamp, $%d)", len(args)-1, len(args))
+		}
+
+		// created_at and id are selected again at the end for the cursor
+		args = append(args, limit, offset)
+		rows, err := h.db.QueryContext(r.Context(),
+			fmt.Sprintf(`SELECT %s, created_at, id FROM files %s ORDER BY created_at, id LIMIT $%d OFFSET $%d`,
+				strings.Join(fields, ", "), where, len(args)-1, len(args)),
+			args...)
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to list files", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to list files")
+			return
+		}
+		defer rows.Close()
+
+		var last listCursor
+		for rows.Next() {
+			dest := make([]any, len(fields), len(fields)+2)
+			for i, f := range fields {
+				dest[i] = listFields[f]()
+			}
+			var createdAt time.Time
+			dest = append(dest, &createdAt, &last.id)
+			if err := rows.Scan(dest...); err != nil {
+				h.log(r.Context()).Error(r.Context(), "Failed to scan file row", slog.String("error", err.Error()))
+				writeDBError(w, err, "Failed to list files")
+				return
+			}
+			last.createdAt = createdAt.Format(cursorTimeLayout)
+			item := make(map[string]any, len(fields))
+			for i, f := range fields {
+				item[f] = dest[i]
+			}
+			page.Items = append(page.Items, item)
+		}
+		if err := rows.Err(); err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to list files", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to list files")
+			return
+		}
+
+		// A full page may be followed by more
+		if len(page.Items) == limit {
+			page.NextCursor = last.String()
+		}
+		encodeResponse(w, r, http.StatusOK, page)
+	}
+}
+
+// cursorTimeLayout keeps the microsecond precision of TIMESTAMP columns
+const cursorTimeLayout = "2006-01-02 15:04:05.999999"
+
+// listCursor is the position after the last file of a page in ListFiles order
+type listCursor struct {
+	createdAt string
+	id        int64
+}
+
+// String encodes the cursor as an opaque URL-safe token
+func (c listCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.createdAt + "|" + strconv.FormatInt(c.id, 10)))
+}
+
+// parseCursor decodes a cursor from listCursor.String
+func parseCursor(raw string) (listCursor, error) {
+	invalid := errors.New("invalid cursor")
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return listCursor{}, invalid
+	}
+	createdAt, rawID, ok := strings.Cut(string(b), "|")
+	if !ok {
+		return listCursor{}, invalid
+	}
+	if _, err := time.Parse(cursorTimeLayout, createdAt); err != nil {
+		return listCursor{}, invalid
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return listCursor{}, invalid
+	}
+	return listCursor{createdAt: createdAt, id: id}, nil
+}
+
+// likeEscaper escapes LIKE wildcards so q matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// listFilters builds the WHERE clause and its arguments for the listing
+// filters, limited to the files of owner unless it's NULL
+func listFilters(query url.Values, owner sql.NullString) (string, []any) {
+	var (
+		conds = []string{"deleted_at IS NULL", notExpired}
+		args  []any
+	)
+	if owner.Valid {
+		args = append(args, owner.String)
+		conds = append(conds, fmt.Sprintf("owner_id = $%d", len(args)))
+	}
+	if q := query.Get("q"); q != "" {
+		args = append(args, "%"+likeEscaper.Replace(q)+"%")
+		conds = append(conds, fmt.Sprintf("filename ILIKE $%d", len(args)))
+	}
+	for key, values := range query {
+		metaKey, ok := strings.CutPrefix(key, "meta.")
+		if !ok || metaKey == "" {
+			continue
+		}
+		args = append(args, metaKey, values[0])
+		conds = append(conds, fmt.Sprintf("metadata->>$%d = $%d", len(args)-1, len(args)))
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// parseFields validates a comma separated ?fields= value against the allowlist
+func parseFields(raw string) ([]string, error) {
+	if raw == "" {
+		return allListFields, nil
+	}
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if _, ok := listFields[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q, allowed fields: %s", f, strings.Join(allListFields, ", "))
+		}
+		if !slices.Contains(fields, f) {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// parsePage parses ?limit= and ?offset=, applying defaults when absent
+func parsePage(rawLimit, rawOffset string) (limit, offset int, err error) {
+	limit = defaultListLimit
+	if rawLimit != "" {
+		limit, err = strconv.Atoi(rawLimit)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+	}
+	if rawOffset != "" {
+		offset, err = strconv.Atoi(rawOffset)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{raw: "", want: allListFields},
+		{raw: "id", want: []string{"id"}},
+		{raw: "filename, id", want: []string{"filename", "id"}},
+		{raw: "id,id", want: []string{"id"}},
+		{raw: "content", wantErr: true},
+		{raw: "id,", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseFields(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		limit, offset string
+		wantLimit     int
+		wantOffset    int
+		wantErr       bool
+	}{
+		{wantLimit: defaultListLimit},
+		{limit: "10", offset: "20", wantLimit: 10, wantOffset: 20},
+		{limit: "1000", wantLimit: maxListLimit},
+		{limit: "1001", wantErr: true},
+		{limit: "0", wantErr: true},
+		{limit: "ten", wantErr: true},
+		{offset: "-1", wantErr: true},
+		{offset: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.limit+"/"+tt.offset, func(t *testing.T) {
+			limit, offset, err := parsePage(tt.limit, tt.offset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if limit != tt.wantLimit || offset != tt.wantOffset {
+				t.Errorf("got limit %d offset %d, want %d %d", limit, offset, tt.wantLimit, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := listCursor{createdAt: "2024-05-01 10:20:30.123456", id: 42}
+	got, err := parseCursor(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != c {
+		t.Errorf("parseCursor = %+v, want %+v", got, c)
+	}
+}
+
+func TestParseCursorRejects(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for _, raw := range []string{
+		"",
+		"not base64!",
+		encode("2024-05-01 10:20:30"),
+		encode("yesterday|42"),
+		encode("2024-05-01 10:20:30|x"),
+		encode("2024-05-01 10:20:30|1; DROP TABLE files"),
+	} {
+		if _, err := parseCursor(raw); err == nil {
+			t.Errorf("parseCursor(%q) accepted", raw)
+		}
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		owner sql.NullString
+		conds []string
+		args  []any
+	}{
+		{name: "none", conds: []string{"deleted_at IS NULL", notExpired}},
+		{name: "owner", owner: sql.NullString{String: "u1", Valid: true}, conds: []string{"owner_id = $1"}, args: []any{"u1"}},
+		{name: "search", query: "q=report", conds: []string{"filename ILIKE $1"}, args: []any{"%report%"}},
+		{name: "search escapes wildcards", query: "q=" + url.QueryEscape(`50%_off\`), args: []any{`%50\%\_off\\%`}},
+		{name: "metadata", query: "meta.project=apollo", conds: []string{"metadata->>$1 = $2"}, args: []any{"project", "apollo"}},
+		{name: "empty metadata key ignored", query: "meta.=x", args: nil},
+		{
+			name:  "combined",
+			query: "q=a&meta.k=v",
+			owner: sql.NullString{String: "u1", Valid: true},
+			conds: []string{"owner_id = $1", "filename ILIKE $2", "metadata->>$3 = $4"},
+			args:  []any{"u1", "%a%", "k", "v"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			where, args := listFilters(query, tt.owner)
+			for _, c := range tt.conds {
+				if !strings.Contains(where, c) {
+					t.Errorf("WHERE %q is missing %q", where, c)
+				}
+			}
+			if !slices.Equal(args, tt.args) {
+				t.Errorf("args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the hand-maintained OpenAPI 3 description of the API,
+// update it together with the routes in main.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec serves the OpenAPI document
+func (h *Handlers) OpenAPISpec() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	}
+}
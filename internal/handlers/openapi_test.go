@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&Handlers{}).OpenAPISpec()(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", spec.OpenAPI)
+	}
+
+	// The routes registered in main
+	routes := []string{
+		"GET /",
+		"POST /add",
+		"POST /add/batch",
+		"GET /jobs/{id}",
+		"POST /uploads",
+		"GET /uploads/{id}",
+		"PATCH /uploads/{id}",
+		"POST /uploads/{id}/commit",
+		"GET /files",
+		"GET /files/archive",
+		"GET /files/{id}",
+		"HEAD /files/{id}",
+		"PUT /files/raw",
+		"POST /files/fetch",
+		"PUT /files/{id}",
+		"DELETE /files/{id}",
+		"POST /files/{id}/restore",
+		"POST /files/{id}/sign",
+		"GET /files/{id}/download-url",
+		"POST /files/{id}/verify",
+		"GET /files/{id}/thumbnail",
+		"GET /files/{id}/meta",
+		"PATCH /files/{id}/tags",
+		"POST /files/{id}/transfer",
+		"GET /auth/verify",
+		"GET /events",
+		"GET /healthz",
+		"GET /openapi.json",
+		"GET /audit",
+		"GET /stats",
+		"GET /admin/keys",
+		"POST /admin/keys/{id}/revoke",
+	}
+	for _, route := range routes {
+		t.Run(route, func(t *testing.T) {
+			method, path, _ := strings.Cut(route, " ")
+			if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+				t.Errorf("spec doesn't describe %s", route)
+			}
+		})
+	}
+}
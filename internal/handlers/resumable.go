@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"inv/internal/dberr"
+	"inv/internal/membudget"
+	"inv/internal/middlewares"
+)
+
+// uploadSession is the progress of a resumable upload
+type uploadSession struct {
+	ID     string `json:"id"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+// CreateUpload starts a resumable upload from a JSON body
+// {"filename", "size", "mime_type", "metadata"}. Chunks are then sent with
+// PATCH /uploads/{id} and assembled into a file by POST /uploads/{id}/commit.
+func (h *Handlers) CreateUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Filename string          `json:"filename"`
+			Size     int64           `json:"size"`
+			MimeType string          `json:"mime_type"`
+			Metadata json.RawMessage `json:"metadata"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `Body must be a JSON object like {"filename": "a.bin", "size": 1024}`, http.StatusBadRequest)
+			return
+		}
+		filename, err := sanitizeFilename(req.Filename)
+		if err != nil {
+			http.Error(w, "Invalid filename: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.checkExtension(filename); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Size <= 0 {
+			http.Error(w, "size must be positive", http.StatusBadRequest)
+			return
+		}
+		if req.Size > h.cfg.MaxUploadBytes {
+			writeTooLarge(w, h.cfg.MaxUploadBytes, req.Size)
+			return
+		}
+		// Checked again on commit, when the type may be sniffed from the content
+		if limit := h.sizeLimit(req.MimeType); req.Size > limit {
+			writeTooLarge(w, limit, req.Size)
+			return
+		}
+		metadata, err := parseMetadata(string(req.Metadata))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id, err := randomID()
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to create upload", slog.String("error", err.Error()))
+			http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+			return
+		}
+		owner, _ := middlewares.PrincipalFrom(r.Context())
+		_, err = h.db.ExecContext(r.Context(), `
+            INSERT INTO upload_sessions (id, owner_id, filename, mime_type, metadata, size)
+            VALUES ($1, $2, $3, $4, $5::jsonb, $6)`,
+			id, owner.Subject, filename, req.MimeType, metadata, req.Size)
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to create upload", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to create upload")
+			return
+		}
+
+		w.Header().Set("Location", "/uploads/"+id)
+		writeJSON(w, http.StatusCreated, uploadSession{ID: id, Size: req.Size})
+	}
+}
+
+// GetUpload reports how much of the upload identified by the {id} path value
+// has been received, so a client can resume from there
+func (h *Handlers) GetUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := h.loadSession(r)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Upload not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load upload", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to load upload")
+			return
+		}
+		writeJSON(w, http.StatusOK, s)
+	}
+}
+
+// AppendUpload appends the body to the upload identified by the {id} path
+// value. Content-Range must continue exactly where the received bytes end,
+// otherwise 409 reports the offset to resume from.
+func (h *Handlers) AppendUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := h.loadSession(r)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Upload not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load upload", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to load upload")
+			return
+		}
+
+		start, end, total, err := parseContentRange(r.Header.Get("Content-Range"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if total != s.Size {
+			http.Error(w, fmt.Sprintf("Content-Range total must be the upload size %d", s.Size), http.StatusBadRequest)
+			return
+		}
+		if start != s.Offset {
+			writeJSON(w, http.StatusConflict, s)
+			return
+		}
+
+		// The chunk is held in memory until it's appended
+		body := h.memory.Reader(http.MaxBytesReader(w, r.Body, end-start+2))
+		defer body.Release()
+		chunk, err := io.ReadAll(body)
+		if errors.Is(err, membudget.ErrExhausted) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Server is busy, retry later", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil || int64(len(chunk)) != end-start+1 {
+			h.logTruncated(r, &sizeMismatchError{Expected: end - start + 1, Read: int64(len(chunk))})
+			http.Error(w, "Body length doesn't match Content-Range", http.StatusBadRequest)
+			return
+		}
+
+		s.Offset, err = h.appendChunk(r.Context(), s.ID, start, chunk)
+		if errors.Is(err, sql.ErrNoRows) {
+			if s, err = h.loadSession(r); err == nil {
+				writeJSON(w, http.StatusConflict, s)
+				return
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Upload not found", http.StatusNotFound)
+				return
+			}
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to append to upload", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to append to upload")
+			return
+		}
+		writeJSON(w, http.StatusOK, s)
+	}
+}
+
+// appendChunk stores chunk as the part of upload id starting at offset and
+// returns the bytes received so far. The offset check makes concurrent or
+// repeated chunks fail with sql.ErrNoRows instead of being stored twice.
+func (h *Handlers) appendChunk(ctx context.Context, id string, offset int64, chunk []byte) (int64, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin append: %w", err)
+	}
+	defer tx.Rollback()
+
+	var received int64
+	err = tx.QueryRowContext(ctx, `
+        UPDATE upload_sessions
+        SET received = received + $3, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND received = $2
+        RETURNING received`,
+		id, offset, int64(len(chunk))).Scan(&received)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO upload_chunks (upload_id, start_offset, data)
+        VALUES ($1, $2, $3)`,
+		id, offset, chunk)
+	if err != nil {
+		return 0, fmt.Errorf("insert chunk: %w", err)
+	}
+	return received, tx.Commit()
+}
+
+// errCommitting is returned by claimUpload while another commit of the same
+// upload is in progress
+var errCommitting = errors.New("upload is already being committed")
+
+// CommitUpload stores the complete upload identified by the {id} path value
+// as a file, applying the ON_DUPLICATE policy, and ends the session. The
+// session stays locked while the file is stored, so a concurrent commit of
+// the same upload gets 409 rather than storing it twice.
+func (h *Handlers) CommitUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tx, err := h.db.BeginTx(r.Context(), nil)
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load upload", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to load upload")
+			return
+		}
+		defer tx.Rollback()
+
+		f, s, err := h.claimUpload(r, tx)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Upload not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errCommitting) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load upload", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to load upload")
+			return
+		}
+		if s.Offset != s.Size {
+			writeJSON(w, http.StatusConflict, s)
+			return
+		}
+		if limit := h.sizeLimit(f.MimeType); s.Size > limit {
+			writeTooLarge(w, limit, s.Size)
+			return
+		}
+
+		stored, replaced, err := h.storeNamed(r.Context(), f)
+		if taken := (*nameTakenError)(nil); errors.As(err, &taken) {
+			writeError(w, http.StatusConflict, taken.Error())
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to save file", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to save file to database")
+			return
+		}
+		h.audit(r.Context(), h.newAuditEntry(r, AuditUpload, stored.ID))
+
+		// The file is stored either way, a session left behind is swept later
+		_, err = tx.ExecContext(r.Context(), `DELETE FROM upload_sessions WHERE id = $1`, s.ID)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			h.log(r.Context()).Warn(r.Context(), "Failed to remove finished upload", slog.String("error", err.Error()))
+		}
+
+		status := http.StatusCreated
+		if stored.Deduped || replaced {
+			status = http.StatusOK
+		}
+		writeJSON(w, status, map[string]int64{"id": stored.ID})
+	}
+}
+
+// claimUpload locks the upload identified by the {id} path value in tx if it
+// belongs to the caller, and assembles its chunks into a file once all bytes
+// were received. It returns errCommitting when the upload is locked already.
+func (h *Handlers) claimUpload(r *http.Request, tx *sql.Tx) (newFile, uploadSession, error) {
+	owner, _ := middlewares.PrincipalFrom(r.Context())
+	var (
+		f        = newFile{Owner: owner.Subject}
+		s        = uploadSession{ID: r.PathValue("id")}
+		metadata rawJSON
+	)
+	err := tx.QueryRowContext(r.Context(), `
+        SELECT filename, mime_type, metadata, size, received
+        FROM upload_sessions
+        WHERE id = $1 AND owner_id = $2
+        FOR UPDATE NOWAIT`,
+		s.ID, owner.Subject).Scan(&f.Filename, &f.MimeType, &metadata, &s.Size, &s.Offset)
+	if dberr.IsLockNotAvailable(err) {
+		return newFile{}, uploadSession{}, errCommitting
+	}
+	if err != nil || s.Offset != s.Size {
+		return newFile{}, s, err
+	}
+
+	rows, err := tx.QueryContext(r.Context(), `
+        SELECT data FROM upload_chunks WHERE upload_id = $1 ORDER BY start_offset`, s.ID)
+	if err != nil {
+		return newFile{}, s, fmt.Errorf("load chunks: %w", err)
+	}
+	defer rows.Close()
+	f.Content = make([]byte, 0, s.Size)
+	for rows.Next() {
+		var chunk []byte
+		if err := rows.Scan(&chunk); err != nil {
+			return newFile{}, s, fmt.Errorf("load chunks: %w", err)
+		}
+		f.Content = append(f.Content, chunk...)
+	}
+	if err := rows.Err(); err != nil {
+		return newFile{}, s, fmt.Errorf("load chunks: %w", err)
+	}
+	if int64(len(f.Content)) != s.Size {
+		return newFile{}, s, fmt.Errorf("chunks hold %d of %d bytes", len(f.Content), s.Size)
+	}
+
+	f.MimeType = detectMimeType(f.MimeType, f.Content)
+	f.Metadata = string(metadata)
+	return f, s, nil
+}
+
+// loadSession loads the upload identified by the {id} path value if it
+// belongs to the caller, sql.ErrNoRows otherwise
+func (h *Handlers) loadSession(r *http.Request) (uploadSession, error) {
+	owner, _ := middlewares.PrincipalFrom(r.Context())
+	s := uploadSession{ID: r.PathValue("id")}
+	err := h.db.QueryRowContext(r.Context(), `
+        SELECT received, size FROM upload_sessions WHERE id = $1 AND owner_id = $2`,
+		s.ID, owner.Subject).Scan(&s.Offset, &s.Size)
+	return s, err
+}
+
+// parseContentRange parses a "bytes start-end/total" Content-Range header
+func parseContentRange(header string) (start, end, total int64, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	rng, rawTotal, ok2 := strings.Cut(spec, "/")
+	rawStart, rawEnd, ok3 := strings.Cut(rng, "-")
+	if !ok || !ok2 || !ok3 {
+		return 0, 0, 0, errors.New(`Content-Range must look like "bytes 0-1023/4096"`)
+	}
+	start, err1 := strconv.ParseInt(rawStart, 10, 64)
+	end, err2 := strconv.ParseInt(rawEnd, 10, 64)
+	total, err3 := strconv.ParseInt(rawTotal, 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || start < 0 || end < start || end >= total {
+		return 0, 0, 0, errors.New("Content-Range has an invalid range")
+	}
+	return start, end, total, nil
+}
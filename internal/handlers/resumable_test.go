@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"inv/internal/config"
+	"inv/internal/logging"
+	"inv/internal/testdb"
+)
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header            string
+		start, end, total int64
+		wantErr           bool
+	}{
+		{header: "bytes 0-1023/4096", start: 0, end: 1023, total: 4096},
+		{header: "bytes 4095-4095/4096", start: 4095, end: 4095, total: 4096},
+		{header: "bytes 0-0/1", start: 0, end: 0, total: 1},
+		{header: "", wantErr: true},
+		{header: "0-1023/4096", wantErr: true},
+		{header: "bytes 0-1023", wantErr: true},
+		{header: "bytes 0-1023/*", wantErr: true},
+		{header: "bytes */4096", wantErr: true},
+		{header: "bytes -1-10/20", wantErr: true},
+		{header: "bytes 10-5/20", wantErr: true},
+		{header: "bytes 0-4096/4096", wantErr: true},
+		{header: "bytes a-b/c", wantErr: true},
+		{header: "items 0-1/2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			start, end, total, err := parseContentRange(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if start != tt.start || end != tt.end || total != tt.total {
+				t.Errorf("got %d-%d/%d, want %d-%d/%d", start, end, total, tt.start, tt.end, tt.total)
+			}
+		})
+	}
+}
+
+// uploadRequest runs handler on upload id as owner
+func uploadRequest(handler http.HandlerFunc, method, id, owner string, body string, header ...string) *httptest.ResponseRecorder {
+	r := as(httptest.NewRequest(method, "/uploads/"+id, strings.NewReader(body)), owner)
+	r.SetPathValue("id", id)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
+
+func TestCreateUploadRejects(t *testing.T) {
+	h := &Handlers{cfg: config.Config{MaxUploadBytes: 100}, logger: logging.Discard}
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "not json", body: "size=10", want: http.StatusBadRequest},
+		{name: "no filename", body: `{"size": 10}`, want: http.StatusBadRequest},
+		{name: "zero size", body: `{"filename": "a.bin", "size": 0}`, want: http.StatusBadRequest},
+		{name: "negative size", body: `{"filename": "a.bin", "size": -1}`, want: http.StatusBadRequest},
+		{name: "too large", body: `{"filename": "a.bin", "size": 101}`, want: http.StatusRequestEntityTooLarge},
+		{name: "bad metadata", body: `{"filename": "a.bin", "size": 10, "metadata": [1]}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := uploadRequest(h.CreateUpload(), http.MethodPost, "", "u1", tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d %q, want %d", rec.Code, rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+// createUpload starts an upload of size bytes for owner, returning its id
+func createUpload(t *testing.T, h *Handlers, owner string, size int) string {
+	t.Helper()
+	rec := uploadRequest(h.CreateUpload(), http.MethodPost, "", owner, fmt.Sprintf(`{"filename": "resumed.txt", "size": %d}`, size))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d %q, want 201", rec.Code, rec.Body.String())
+	}
+	var s uploadSession
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	return s.ID
+}
+
+func TestResumableUpload(t *testing.T) {
+	db := testdb.Open(t)
+	h := newTestHandlers(t, db, nil)
+	owner, other := testOwner(t, db), testOwner(t, db)
+	id := createUpload(t, h, owner, 10)
+
+	steps := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		owner   string
+		body    string
+		rng     string
+		want    int
+		offset  int64
+	}{
+		{name: "first chunk", handler: h.AppendUpload(), method: http.MethodPatch, owner: owner, body: "0123", rng: "bytes 0-3/10", want: http.StatusOK, offset: 4},
+		{name: "progress", handler: h.GetUpload(), method: http.MethodGet, owner: owner, want: http.StatusOK, offset: 4},
+		{name: "other user", handler: h.GetUpload(), method: http.MethodGet, owner: other, want: http.StatusNotFound},
+		{name: "gap", handler: h.AppendUpload(), method: http.MethodPatch, owner: owner, body: "6789", rng: "bytes 6-9/10", want: http.StatusConflict, offset: 4},
+		{name: "repeated chunk", handler: h.AppendUpload(), method: http.MethodPatch, owner: owner, body: "0123", rng: "bytes 0-3/10", want: http.StatusConflict, offset: 4},
+		{name: "wrong total", handler: h.AppendUpload(), method: http.MethodPatch, owner: owner, body: "45", rng: "bytes 4-5/11", want: http.StatusBadRequest},
+		{name: "short body", handler: h.AppendUpload(), method: http.MethodPatch, owner: owner, body: "4", rng: "bytes 4-5/10", want: http.StatusBadRequest},
+		{name: "early commit", handler: h.CommitUpload(), method: http.MethodPost, owner: owner, want: http.StatusConflict, offset: 4},
+		{name: "last chunk", handler: h.AppendUpload(), method: http.MethodPatch, owner: owner, body: "456789", rng: "bytes 4-9/10", want: http.StatusOK, offset: 10},
+	}
+	for _, st := range steps {
+		rec := uploadRequest(st.handler, st.method, id, st.owner, st.body, "Content-Range", st.rng)
+		if rec.Code != st.want {
+			t.Fatalf("%s: status = %d %q, want %d", st.name, rec.Code, rec.Body.String(), st.want)
+		}
+		var s uploadSession
+		if st.offset != 0 && (json.Unmarshal(rec.Body.Bytes(), &s) != nil || s.Offset != st.offset) {
+			t.Errorf("%s: body %q, want offset %d", st.name, rec.Body.String(), st.offset)
+		}
+	}
+
+	rec := uploadRequest(h.CommitUpload(), http.MethodPost, id, owner, "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("commit status = %d %q, want 201", rec.Code, rec.Body.String())
+	}
+	var stored struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if got := fileRequestFor(h.GetFile(), http.MethodGet, stored.ID, nil, owner).Body.String(); got != "0123456789" {
+		t.Errorf("content = %q, want %q", got, "0123456789")
+	}
+	if rec := uploadRequest(h.CommitUpload(), http.MethodPost, id, owner, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second commit status = %d, want 404", rec.Code)
+	}
+}
+
+func TestCommitUploadLocked(t *testing.T) {
+	db := testdb.Open(t)
+	h := newTestHandlers(t, db, nil)
+	owner := testOwner(t, db)
+	id := createUpload(t, h, owner, 2)
+	if rec := uploadRequest(h.AppendUpload(), http.MethodPatch, id, owner, "ok", "Content-Range", "bytes 0-1/2"); rec.Code != http.StatusOK {
+		t.Fatalf("append status = %d, want 200", rec.Code)
+	}
+
+	// A commit in progress holds the session row
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT 1 FROM upload_sessions WHERE id = $1 FOR UPDATE`, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := uploadRequest(h.CommitUpload(), http.MethodPost, id, owner, ""); rec.Code != http.StatusConflict {
+		t.Errorf("concurrent commit status = %d %q, want 409", rec.Code, rec.Body.String())
+	}
+	tx.Rollback()
+	if rec := uploadRequest(h.CommitUpload(), http.MethodPost, id, owner, ""); rec.Code != http.StatusCreated {
+		t.Errorf("commit after release status = %d %q, want 201", rec.Code, rec.Body.String())
+	}
+}
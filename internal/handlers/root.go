@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// serviceName identifies the service in the root descriptor
+const serviceName = "file-service"
+
+// serviceDescriptor is the response of Root
+type serviceDescriptor struct {
+	Service   string   `json:"service"`
+	Version   string   `json:"version"`
+	Endpoints []string `json:"endpoints"`
+}
+
+// Root describes the service at "/" so anyone poking at the server can tell
+// what it is. Endpoints are listed from the OpenAPI document as "METHOD
+// /path". Only GET and HEAD are served, other methods get 404.
+func (h *Handlers) Root(version string) http.HandlerFunc {
+	desc := serviceDescriptor{Service: serviceName, Version: version, Endpoints: specEndpoints()}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, desc)
+	}
+}
+
+// specEndpoints lists the operations of openAPISpec
+func specEndpoints() []string {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		return []string{}
+	}
+	endpoints := []string{}
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			if method == "parameters" {
+				continue
+			}
+			endpoints = append(endpoints, strings.ToUpper(method)+" "+path)
+		}
+	}
+	// By path, then method
+	sort.Slice(endpoints, func(i, j int) bool {
+		mi, pi, _ := strings.Cut(endpoints[i], " ")
+		mj, pj, _ := strings.Cut(endpoints[j], " ")
+		if pi != pj {
+			return pi < pj
+		}
+		return mi < mj
+	})
+	return endpoints
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"inv/internal/signedurl"
+)
+
+// Lifetime of signed URLs, adjustable per request with ?ttl
+const (
+	defaultSignedTTL = time.Hour
+	maxSignedTTL     = 7 * 24 * time.Hour
+)
+
+// SignFile returns a download URL for the file identified by the {id} path
+// value that works without credentials until it expires. ?ttl sets the
+// lifetime as a duration such as 15m, up to seven days. Answers 404 unless
+// SIGNING_SECRET is set.
+func (h *Handlers) SignFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.signing() {
+			http.NotFound(w, r)
+			return
+		}
+
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+
+		ttl := defaultSignedTTL
+		if raw := r.URL.Query().Get("ttl"); raw != "" {
+			ttl, err = time.ParseDuration(raw)
+			if err != nil || ttl <= 0 || ttl > maxSignedTTL {
+				http.Error(w, "ttl must be a positive duration up to "+maxSignedTTL.String(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if _, err := h.lookupFile(r.Context(), id); errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load file from database", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to load file")
+			return
+		}
+
+		link, expires := h.signedURL(id, ttl)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"url":        link,
+			"expires_at": expires,
+		})
+	}
+}
+
+// signing reports whether signed download URLs are enabled
+func (h *Handlers) signing() bool {
+	return h.cfg.SigningSecret != ""
+}
+
+// signedURL returns the path of a download of file id signed for ttl
+func (h *Handlers) signedURL(id int64, ttl time.Duration) (string, time.Time) {
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	query := url.Values{
+		"expires": {strconv.FormatInt(expires.Unix(), 10)},
+		"sig":     {signedurl.Sign(h.cfg.SigningSecret, id, expires)},
+	}
+	return "/files/" + strconv.FormatInt(id, 10) + "?" + query.Encode(), expires.UTC()
+}
+
+// SignedRequest reports whether r carries a valid, unexpired signature for
+// the file identified by its {id} path value
+func (h *Handlers) SignedRequest(r *http.Request) bool {
+	sig := r.URL.Query().Get("sig")
+	if sig == "" || !h.signing() {
+		return false
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return false
+	}
+	return signedurl.Verify(h.cfg.SigningSecret, id, r.URL.Query().Get("expires"), sig, time.Now())
+}
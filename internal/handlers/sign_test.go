@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"inv/internal/config"
+)
+
+func TestSignedRequest(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	h := &Handlers{cfg: config.Config{SigningSecret: secret}}
+	link, _ := h.signedURL(42, time.Minute)
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	expired, _ := h.signedURL(42, -time.Minute)
+
+	tests := []struct {
+		name   string
+		secret string
+		id     string
+		query  string
+		want   bool
+	}{
+		{name: "valid", secret: secret, id: "42", query: u.RawQuery, want: true},
+		{name: "signing disabled", id: "42", query: u.RawQuery},
+		{name: "other file", secret: secret, id: "43", query: u.RawQuery},
+		{name: "bad id", secret: secret, id: "x", query: u.RawQuery},
+		{name: "unsigned", secret: secret, id: "42"},
+		{name: "missing expiry", secret: secret, id: "42", query: "sig=" + q.Get("sig")},
+		{name: "expired", secret: secret, id: "42", query: expired[len("/files/42?"):]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handlers{cfg: config.Config{SigningSecret: tt.secret}}
+			r := httptest.NewRequest(http.MethodGet, "/files/"+tt.id+"?"+tt.query, nil)
+			r.SetPathValue("id", tt.id)
+			if got := h.SignedRequest(r); got != tt.want {
+				t.Errorf("SignedRequest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignFileDisabled(t *testing.T) {
+	h := &Handlers{}
+	r := httptest.NewRequest(http.MethodPost, "/files/1/sign", nil)
+	r.SetPathValue("id", "1")
+	rec := httptest.NewRecorder()
+	h.SignFile()(rec, r)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 without SIGNING_SECRET", rec.Code)
+	}
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"inv/internal/dberr"
+)
+
+// spoolRetryInterval spaces rescans of the spool, which retry uploads that
+// failed while the database was unavailable
+const spoolRetryInterval = 5 * time.Second
+
+// spoolEntry is an upload waiting in the spool
+type spoolEntry struct {
+	File  newFile
+	Audit auditEntry
+}
+
+// spool stages uploads on local disk until RunSpool stores them. Each
+// upload is a <job id>.spool file, written to a temp file, synced and
+// renamed so a crash never leaves a partial entry behind. Entries that
+// can't be stored are renamed to <job id>.failed for inspection.
+type spool struct {
+	dir  string
+	wake chan struct{}
+}
+
+func newSpool(dir string) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create spool dir: %w", err)
+	}
+	return &spool{dir: dir, wake: make(chan struct{}, 1)}, nil
+}
+
+func (s *spool) path(id string) string {
+	return filepath.Join(s.dir, id+".spool")
+}
+
+// write durably stores e under id and wakes the worker
+func (s *spool) write(id string, e spoolEntry) error {
+	tmp, err := os.CreateTemp(s.dir, id+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("create spool file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+	if err := gob.NewEncoder(tmp).Encode(e); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write spool file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync spool file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close spool file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(id)); err != nil {
+		return fmt.Errorf("commit spool file: %w", err)
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *spool) read(id string) (spoolEntry, error) {
+	var e spoolEntry
+	file, err := os.Open(s.path(id))
+	if err != nil {
+		return e, err
+	}
+	defer file.Close()
+	if err := gob.NewDecoder(file).Decode(&e); err != nil {
+		return e, fmt.Errorf("decode spool file: %w", err)
+	}
+	return e, nil
+}
+
+// pending returns the ids of the spooled uploads, oldest first. Temp files
+// over an hour old, left by a crash mid-write, are removed. Their clients
+// never got an id.
+func (s *spool) pending() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("list spool dir: %w", err)
+	}
+	type spooled struct {
+		id  string
+		mod time.Time
+	}
+	var found []spooled
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > time.Hour {
+				os.Remove(filepath.Join(s.dir, name))
+			}
+			continue
+		}
+		id, ok := strings.CutSuffix(name, ".spool")
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // stored and removed meanwhile
+		}
+		found = append(found, spooled{id: id, mod: info.ModTime()})
+	}
+	slices.SortFunc(found, func(a, b spooled) int { return a.mod.Compare(b.mod) })
+
+	ids := make([]string, len(found))
+	for i, f := range found {
+		ids[i] = f.id
+	}
+	return ids, nil
+}
+
+// fail sets the entry aside so it isn't retried
+func (s *spool) fail(id string) error {
+	return os.Rename(s.path(id), filepath.Join(s.dir, id+".failed"))
+}
+
+// storeSpooled writes f to the spool and returns the id of the job tracking
+// it, the upload is stored by RunSpool
+func (h *Handlers) storeSpooled(f newFile, audit auditEntry) (string, error) {
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+	if err := h.spool.write(id, spoolEntry{File: f, Audit: audit}); err != nil {
+		return "", err
+	}
+	h.jobs.add(id)
+	return id, nil
+}
+
+// RunSpool stores spooled uploads until ctx is done, starting with any left
+// from before a restart. It returns at once when SPOOL_DIR isn't set. An
+// upload stored just before a crash, with its spool file not yet removed,
+// is stored again on the next start.
+func (h *Handlers) RunSpool(ctx context.Context) {
+	if h.spool == nil {
+		return
+	}
+	for {
+		h.drainSpool(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.spool.wake:
+		case <-time.After(spoolRetryInterval):
+		}
+	}
+}
+
+// drainSpool stores the spooled uploads in order, stopping early while the
+// database is unavailable
+func (h *Handlers) drainSpool(ctx context.Context) {
+	ids, err := h.spool.pending()
+	if err != nil {
+		h.logger.Error(ctx, "Failed to read spool", slog.String("error", err.Error()))
+		return
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		h.jobs.add(id) // known already unless spooled before a restart
+		err := h.storeSpoolEntry(ctx, id)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			h.logger.Warn(ctx, "Failed to store spooled upload, retrying later",
+				slog.String("job_id", id),
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+	}
+}
+
+// storeSpoolEntry stores the spooled upload id and removes it from the
+// spool. Errors retrying may fix are returned, the entry is failed
+// otherwise.
+func (h *Handlers) storeSpoolEntry(ctx context.Context, id string) error {
+	e, err := h.spool.read(id)
+	if errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	var stored storedFile
+	if err == nil {
+		stored, err = h.storeFile(ctx, e.File)
+		if err != nil && (ctx.Err() != nil || dberr.IsUnavailable(err)) {
+			return err
+		}
+	}
+	if err != nil {
+		h.logger.Error(ctx, "Failed to save spooled file",
+			slog.String("job_id", id),
+			slog.String("error", err.Error()),
+		)
+		if ferr := h.spool.fail(id); ferr != nil {
+			h.logger.Error(ctx, "Failed to set aside spooled file", slog.String("error", ferr.Error()))
+		}
+		h.jobs.finish(id, 0, err)
+		return nil
+	}
+
+	e.Audit.FileID = stored.ID
+	h.audit(ctx, e.Audit)
+	if err := os.Remove(h.spool.path(id)); err != nil {
+		h.logger.Error(ctx, "Failed to remove spooled file", slog.String("error", err.Error()))
+	}
+	h.jobs.finish(id, stored.ID, nil)
+	return nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// statsTTL is how long Stats answers from the last computation, the
+// aggregates scan the whole files table
+const statsTTL = 30 * time.Second
+
+// storageStats summarizes the live files
+type storageStats struct {
+	FileCount        int64            `json:"file_count"`
+	TotalBytes       int64            `json:"total_bytes"`
+	LargestFileBytes int64            `json:"largest_file_bytes"`
+	ByMimeType       map[string]int64 `json:"by_mime_type"`
+}
+
+// statsCache keeps the last computed stats, the zero value is empty
+type statsCache struct {
+	mu    sync.Mutex
+	at    time.Time
+	stats storageStats
+}
+
+// Stats returns the number and size of stored files, with counts per mime
+// type, as JSON. Results are cached for statsTTL.
+func (h *Handlers) Stats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Holding the lock while computing keeps concurrent misses from
+		// each running the aggregates
+		h.stats.mu.Lock()
+		defer h.stats.mu.Unlock()
+
+		if time.Since(h.stats.at) > statsTTL {
+			stats, err := h.computeStats(r.Context())
+			if err != nil {
+				h.log(r.Context()).Error(r.Context(), "Failed to compute stats", slog.String("error", err.Error()))
+				writeDBError(w, err, "Failed to compute stats")
+				return
+			}
+			h.stats.stats, h.stats.at = stats, time.Now()
+		}
+		encodeResponse(w, r, http.StatusOK, h.stats.stats)
+	}
+}
+
+// computeStats runs the aggregates over the live files
+func (h *Handlers) computeStats(ctx context.Context) (storageStats, error) {
+	live := `FROM files WHERE deleted_at IS NULL AND ` + notExpired
+
+	var stats storageStats
+	err := h.db.QueryRowContext(ctx, `SELECT COUNT(*), COALESCE(SUM(size), 0), COALESCE(MAX(size), 0) `+live).
+		Scan(&stats.FileCount, &stats.TotalBytes, &stats.LargestFileBytes)
+	if err != nil {
+		return storageStats{}, err
+	}
+
+	rows, err := h.db.QueryContext(ctx, `SELECT mime_type, COUNT(*) `+live+` GROUP BY mime_type`)
+	if err != nil {
+		return storageStats{}, err
+	}
+	defer rows.Close()
+
+	stats.ByMimeType = make(map[string]int64)
+	for rows.Next() {
+		var (
+			mimeType string
+			count    int64
+		)
+		if err := rows.Scan(&mimeType, &count); err != nil {
+			return storageStats{}, err
+		}
+		stats.ByMimeType[mimeType] = count
+	}
+	return stats, rows.Err()
+}
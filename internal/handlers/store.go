@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"inv/internal/dberr"
+	"inv/internal/storage"
+)
+
+// newFile is a validated upload ready to be stored
+type newFile struct {
+	Filename string
+	MimeType string
+	Content  []byte
+	Owner    string
+	Metadata string
+
+	// TTL in seconds after which the file expires, zero keeps it forever
+	TTL int64
+}
+
+// storedFile describes a file after storeFile
+type storedFile struct {
+	ID        int64
+	Size      int64
+	Checksum  string
+	CreatedAt time.Time
+
+	// Deduped is set when an identical file of the same owner was returned
+	// instead of storing a new one
+	Deduped bool
+}
+
+// storeFile inserts the metadata row and writes the content to the storage
+// backend, then publishes an ActionUploaded event. Size and checksum always
+// describe the original bytes, even when the stored content is compressed.
+// The row is committed only once its content is stored, so readers and
+// dedup lookups never see a file without content.
+//
+// With dedup enabled an identical live file of the same owner is returned
+// instead. Concurrent identical uploads race on the unique dedup index, the
+// losers re-read the winner's row so the race still ends in a dedup.
+func (h *Handlers) storeFile(ctx context.Context, f newFile) (storedFile, error) {
+	sum := checksum(f.Content)
+	// Expiring files are never deduplicated, a permanent upload mustn't
+	// resolve to a file that is about to disappear
+	var dedupKey *string
+	if h.cfg.DedupUploads && f.TTL == 0 {
+		if dup, ok, err := h.findDuplicate(ctx, f.Owner, sum); err != nil || ok {
+			return dup, err
+		}
+		dedupKey = &sum
+	}
+
+	enc, err := h.encodeContent(f.Content)
+	if err != nil {
+		return storedFile{}, err
+	}
+
+	key, err := storage.NewKey()
+	if err != nil {
+		return storedFile{}, fmt.Errorf("generate storage key: %w", err)
+	}
+
+	var ttl *int64
+	if f.TTL > 0 {
+		ttl = &f.TTL
+	}
+
+	// Don't write anything for a request that was canceled meanwhile
+	if err := ctx.Err(); err != nil {
+		return storedFile{}, err
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storedFile{}, fmt.Errorf("begin insert: %w", err)
+	}
+	defer tx.Rollback()
+
+	sf := storedFile{Size: int64(len(f.Content)), Checksum: sum}
+	err = tx.StmtContext(ctx, h.insertFileStmt).QueryRowContext(ctx,
+		f.Filename,
+		f.MimeType,
+		sf.Size,
+		sf.Checksum,
+		f.Owner,
+		f.Metadata,
+		enc.compressed,
+		key,
+		dedupKey,
+		ttl,
+		enc.encrypted,
+	).Scan(&sf.ID, &sf.CreatedAt)
+	if dedupKey != nil && dberr.IsUniqueViolation(err) {
+		tx.Rollback()
+		dup, ok, err := h.findDuplicate(ctx, f.Owner, sum)
+		if err == nil && !ok {
+			err = errors.New("duplicate vanished after unique violation")
+		}
+		return dup, err
+	}
+	if err != nil {
+		return storedFile{}, fmt.Errorf("insert file: %w", err)
+	}
+
+	if err := h.putContent(ctx, tx, key, bytes.NewReader(enc.data)); err != nil {
+		tx.Rollback()
+		h.deleteContent(ctx, key)
+		return storedFile{}, fmt.Errorf("store content: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		h.deleteContent(ctx, key)
+		return storedFile{}, fmt.Errorf("commit file: %w", err)
+	}
+
+	h.publish(FileEvent{
+		Action:   ActionUploaded,
+		ID:       sf.ID,
+		Filename: f.Filename,
+		Size:     sf.Size,
+		Checksum: sf.Checksum,
+		At:       sf.CreatedAt,
+	})
+	return sf, nil
+}
+
+// encoded is content as written to the storage backend
+type encoded struct {
+	data       []byte
+	compressed bool
+	encrypted  bool
+}
+
+// encodeContent returns the bytes to store for content, gzipped when
+// compression at rest is enabled and it pays off, then encrypted when a key
+// is configured
+func (h *Handlers) encodeContent(content []byte) (encoded, error) {
+	enc := encoded{data: content}
+	if h.cfg.CompressAtRest {
+		var err error
+		enc.data, enc.compressed, err = gzipBytes(content)
+		if err != nil {
+			return encoded{}, fmt.Errorf("compress content: %w", err)
+		}
+	}
+	if h.cipher != nil {
+		sealed, err := h.cipher.Seal(enc.data)
+		if err != nil {
+			return encoded{}, fmt.Errorf("encrypt content: %w", err)
+		}
+		enc.data, enc.encrypted = sealed, true
+	}
+	return enc, nil
+}
+
+// openContent loads the stored content of f, decrypted but still gzipped
+// when f.compressed is set
+func (h *Handlers) openContent(ctx context.Context, f fileInfo) (io.ReadCloser, error) {
+	content, err := h.store.Get(ctx, f.key)
+	if err != nil || !f.encrypted {
+		return content, err
+	}
+	defer content.Close()
+
+	if h.cipher == nil {
+		return nil, errors.New("file is encrypted but no encryption key is configured")
+	}
+	sealed, err := io.ReadAll(content)
+	if err != nil {
+		return nil, fmt.Errorf("read content: %w", err)
+	}
+	plain, err := h.cipher.Open(sealed)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(plain)), nil
+}
+
+// openPlain loads the original bytes of f, decrypted and decompressed
+func (h *Handlers) openPlain(ctx context.Context, f fileInfo) (io.ReadCloser, error) {
+	content, err := h.openContent(ctx, f)
+	if err != nil || !f.compressed {
+		return content, err
+	}
+	zr, err := gzip.NewReader(content)
+	if err != nil {
+		content.Close()
+		return nil, fmt.Errorf("decompress content: %w", err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{zr, content}, nil
+}
+
+// replacedFile describes a file after replaceFile
+type replacedFile struct {
+	ID        int64     `json:"id"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum"`
+	MimeType  string    `json:"mime_type"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// errStale is returned by replaceFile when If-Match names another version
+var errStale = errors.New("file has changed, If-Match doesn't match its current ETag")
+
+// replaceFile stores new content for file id under a fresh storage key and
+// points the row at it, so the row and the content it describes change
+// together. Readers keep getting the old content until the update commits,
+// after which it's deleted. sql.ErrNoRows if there's no such file. The
+// replaced file no longer takes part in dedup since its dedup key would have
+// to change.
+//
+// A non-empty ifMatch is an If-Match header the current ETag must satisfy,
+// errStale otherwise. Replaces of a file are serialized on an advisory lock
+// and the check reads the row within the same transaction, so it holds until
+// the new content is in place. Nothing is committed when any step fails.
+func (h *Handlers) replaceFile(ctx context.Context, id int64, u upload, ifMatch string) (replacedFile, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return replacedFile{}, fmt.Errorf("begin replace: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('replace:' || $1::text))`, id); err != nil {
+		return replacedFile{}, fmt.Errorf("lock file: %w", err)
+	}
+
+	f, err := h.lookupFileTx(ctx, tx, id)
+	if err != nil {
+		return replacedFile{}, err
+	}
+	if ifMatch != "" && !etagMatches(ifMatch, f.checksum) {
+		return replacedFile{}, errStale
+	}
+
+	enc, err := h.encodeContent(u.Content)
+	if err != nil {
+		return replacedFile{}, err
+	}
+	key, err := storage.NewKey()
+	if err != nil {
+		return replacedFile{}, fmt.Errorf("generate storage key: %w", err)
+	}
+
+	rf := replacedFile{ID: id, Size: int64(len(u.Content)), Checksum: checksum(u.Content), MimeType: u.MimeType}
+	err = tx.StmtContext(ctx, h.replaceFileStmt).QueryRowContext(ctx,
+		id, rf.Size, rf.MimeType, rf.Checksum, enc.compressed, enc.encrypted, key,
+	).Scan(&rf.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return replacedFile{}, err
+	}
+	if err != nil {
+		return replacedFile{}, fmt.Errorf("update file: %w", err)
+	}
+
+	if err := h.putContent(ctx, tx, key, bytes.NewReader(enc.data)); err != nil {
+		h.deleteContent(ctx, key)
+		return replacedFile{}, fmt.Errorf("store content: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		h.deleteContent(ctx, key)
+		return replacedFile{}, fmt.Errorf("commit replace: %w", err)
+	}
+
+	h.deleteContent(ctx, f.key)
+	h.dropThumbnails(ctx, id)
+	return rf, nil
+}
+
+// putContent writes the content read from r under key. The database backend writes into the
+// row, which only exists or has the new key within tx.
+func (h *Handlers) putContent(ctx context.Context, tx *sql.Tx, key string, r io.Reader) error {
+	if p, ok := h.store.(storage.TxPutter); ok {
+		return p.PutTx(ctx, tx, key, r)
+	}
+	return h.store.Put(ctx, key, r)
+}
+
+// deleteContent removes content no row refers to, failures only leave
+// garbage behind so they are logged
+func (h *Handlers) deleteContent(ctx context.Context, key string) {
+	if err := h.store.Delete(context.WithoutCancel(ctx), key); err != nil {
+		h.log(ctx).Warn(ctx, "Failed to delete unreferenced content",
+			slog.String("storage_key", key),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// findDuplicate looks up a live file of owner with the given checksum
+func (h *Handlers) findDuplicate(ctx context.Context, owner, sum string) (storedFile, bool, error) {
+	return scanDuplicate(h.findDupStmt.QueryRowContext(ctx, owner, sum))
+}
+
+// findDuplicateTx is findDuplicate within tx
+func (h *Handlers) findDuplicateTx(ctx context.Context, tx *sql.Tx, owner, sum string) (storedFile, bool, error) {
+	return scanDuplicate(tx.StmtContext(ctx, h.findDupStmt).QueryRowContext(ctx, owner, sum))
+}
+
+// scanDuplicate reads a row selected by findDupStmt
+func scanDuplicate(row *sql.Row) (storedFile, bool, error) {
+	sf := storedFile{Deduped: true}
+	err := row.Scan(&sf.ID, &sf.Size, &sf.Checksum, &sf.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storedFile{}, false, nil
+	}
+	if err != nil {
+		return storedFile{}, false, fmt.Errorf("find duplicate: %w", err)
+	}
+	return sf, true, nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"inv/internal/config"
+	"inv/internal/testdb"
+)
+
+func TestStoreFileDedupRace(t *testing.T) {
+	db := testdb.Open(t)
+	h := newTestHandlers(t, db, func(c *config.Config) { c.DedupUploads = true })
+	owner := testOwner(t, db)
+
+	const n = 10
+	ids := make([]int64, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f := newFile{Filename: "same.txt", MimeType: "text/plain", Content: []byte("identical content"), Owner: owner}
+			sf, err := h.storeFile(context.Background(), f)
+			ids[i], errs[i] = sf.ID, err
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("upload %d: %v", i, err)
+		}
+		if ids[i] != ids[0] {
+			t.Errorf("upload %d got id %d, upload 0 got %d", i, ids[i], ids[0])
+		}
+	}
+	var rows int
+	if err := db.QueryRow(`SELECT count(*) FROM files WHERE owner_id = $1`, owner).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("%d rows, want 1", rows)
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxTagLength bounds a single normalized tag
+const maxTagLength = 64
+
+// PatchTags adds and removes tags of the file identified by the {id} path
+// value from a {"add": [...], "remove": [...]} body and returns the
+// resulting tag set. The change is applied in a single UPDATE, so
+// concurrent patches never lose each other's updates.
+func (h *Handlers) PatchTags() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Add    []string `json:"add"`
+			Remove []string `json:"remove"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `Body must be a JSON object like {"add": [...], "remove": [...]}`, http.StatusBadRequest)
+			return
+		}
+		add, err := normalizeTags(req.Add)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		remove, err := normalizeTags(req.Remove)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Tags travel as JSON text so both drivers bind them the same way
+		addJSON, _ := json.Marshal(add)
+		removeJSON, _ := json.Marshal(remove)
+
+		var tags rawJSON
+		err = h.patchTagsStmt.QueryRowContext(r.Context(), id, string(addJSON), string(removeJSON), readScope(r.Context())).Scan(&tags)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to update tags", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to update tags")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"tags": tags})
+	}
+}
+
+// normalizeTags trims and lowercases tags, dropping empty ones and duplicates
+func normalizeTags(tags []string) ([]string, error) {
+	out := []string{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || slices.Contains(out, t) {
+			continue
+		}
+		if len(t) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", t, maxTagLength)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "none", want: []string{}},
+		{name: "trimmed and lowercased", tags: []string{" Invoice ", "2024"}, want: []string{"invoice", "2024"}},
+		{name: "duplicates dropped", tags: []string{"a", "A", " a"}, want: []string{"a"}},
+		{name: "empty dropped", tags: []string{"", "  ", "b"}, want: []string{"b"}},
+		{name: "longest", tags: []string{strings.Repeat("x", maxTagLength)}, want: []string{strings.Repeat("x", maxTagLength)}},
+		{name: "too long", tags: []string{strings.Repeat("x", maxTagLength+1)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeTags(tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("normalizeTags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
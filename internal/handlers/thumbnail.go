@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"image"
+	_ "image/gif" // decoders registered for image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"inv/internal/storage"
+
+	"golang.org/x/image/draw"
+)
+
+// Thumbnail widths, images are never scaled up
+const (
+	defaultThumbWidth = 200
+	maxThumbWidth     = 1024
+)
+
+// maxThumbSourcePixels bounds the images decoded for thumbnails, a small
+// compressed file can declare huge dimensions
+const maxThumbSourcePixels = 50_000_000
+
+// errNotImage is returned for files that can't be decoded as an image
+var errNotImage = errors.New("file is not a supported image")
+
+// Thumbnail serves a JPEG of the image identified by the {id} path value,
+// scaled to ?w pixels wide with its aspect ratio kept. Thumbnails are cached
+// in the thumbnails table per file and width.
+func (h *Handlers) Thumbnail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+		width := defaultThumbWidth
+		if raw := r.URL.Query().Get("w"); raw != "" {
+			width, err = strconv.Atoi(raw)
+			if err != nil || width < 1 || width > maxThumbWidth {
+				http.Error(w, "w must be between 1 and "+strconv.Itoa(maxThumbWidth), http.StatusBadRequest)
+				return
+			}
+		}
+
+		f, err := h.lookupFile(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load file from database", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to load file")
+			return
+		}
+		if !strings.HasPrefix(f.mimeType, "image/") {
+			http.Error(w, "Thumbnails are only available for images", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		var thumb []byte
+		err = h.getThumbStmt.QueryRowContext(r.Context(), id, width).Scan(&thumb)
+		if errors.Is(err, sql.ErrNoRows) {
+			thumb, err = h.makeThumbnail(r.Context(), f, width)
+			if errors.Is(err, errNotImage) {
+				http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+				return
+			}
+			if errors.Is(err, storage.ErrNotFound) {
+				h.writeContentMissing(w, r, id)
+				return
+			}
+			if err == nil {
+				if _, err := h.putThumbStmt.ExecContext(r.Context(), id, width, thumb); err != nil {
+					h.log(r.Context()).Warn(r.Context(), "Failed to cache thumbnail", slog.String("error", err.Error()))
+				}
+			}
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to create thumbnail", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to create thumbnail")
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Length", strconv.Itoa(len(thumb)))
+		w.Write(thumb)
+	}
+}
+
+// makeThumbnail decodes the content of f and encodes it as a JPEG at most width pixels wide
+func (h *Handlers) makeThumbnail(ctx context.Context, f fileInfo, width int) ([]byte, error) {
+	content, err := h.openPlain(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	raw, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 || cfg.Width*cfg.Height > maxThumbSourcePixels {
+		return nil, errNotImage
+	}
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, errNotImage
+	}
+
+	b := src.Bounds()
+	width = min(width, b.Dx())
+	height := max(1, b.Dy()*width/b.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// dropThumbnails forgets the cached thumbnails of a file whose content changed
+func (h *Handlers) dropThumbnails(ctx context.Context, id int64) {
+	if _, err := h.db.ExecContext(ctx, `DELETE FROM thumbnails WHERE file_id = $1`, id); err != nil {
+		h.log(ctx).Warn(ctx, "Failed to drop cached thumbnails",
+			slog.Int64("id", id),
+			slog.String("error", err.Error()),
+		)
+	}
+}
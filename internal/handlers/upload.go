@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"inv/internal/config"
+	"inv/internal/membudget"
+	"inv/internal/storage"
+)
+
+// upload is the validated "file" part of a multipart request
+type upload struct {
+	Filename string
+	MimeType string
+	Content  []byte
+}
+
+// readUpload parses the multipart body of r and reads its "file" part. The
+// memory it holds counts against the budget until release is called. On
+// failure the error response has been written and ok is false.
+func (h *Handlers) readUpload(w http.ResponseWriter, r *http.Request) (u upload, release func(), ok bool) {
+	us, release, ok := h.readUploads(w, r)
+	if !ok {
+		return upload{}, nil, false
+	}
+	return us[0], release, true
+}
+
+// readUploads is readUpload for every "file" part of the body, in order
+func (h *Handlers) readUploads(w http.ResponseWriter, r *http.Request) (us []upload, release func(), ok bool) {
+	// A multipart body can't be split without a usable boundary
+	if err := checkMultipart(r.Header.Get("Content-Type")); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, nil, false
+	}
+
+	// Reject bodies over the limit up front when the client declares the
+	// length, and cut off the rest while reading
+	if r.ContentLength > h.cfg.MaxUploadBytes {
+		writeTooLarge(w, h.cfg.MaxUploadBytes, r.ContentLength)
+		return nil, nil, false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadBytes)
+	counted := &countingReadCloser{ReadCloser: ctxReadCloser{ctx: r.Context(), ReadCloser: r.Body}}
+	r.Body = counted
+
+	// Everything read for this request counts against the memory budget
+	body := h.memory.Reader(r.Body)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{body, r.Body}
+	// Failures return a nil release, so the deferred cleanup keeps its own
+	releases := []func(){body.Release}
+	releaseAll := func() {
+		for _, rel := range releases {
+			rel()
+		}
+	}
+	defer func() {
+		if !ok {
+			releaseAll()
+		}
+	}()
+
+	// Parse multipart form, parts past MultipartMemoryBytes spill to temp
+	// files which are removed on release
+	releases = append(releases, func() {
+		if r.MultipartForm != nil {
+			r.MultipartForm.RemoveAll()
+		}
+	})
+	err := r.ParseMultipartForm(h.cfg.MultipartMemoryBytes)
+	if r.Context().Err() != nil {
+		// The client is gone, nobody reads the response
+		return nil, nil, false
+	}
+	if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+		writeTooLarge(w, tooLarge.Limit, r.ContentLength)
+		return nil, nil, false
+	}
+	if errors.Is(err, membudget.ErrExhausted) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server is busy, retry later", http.StatusServiceUnavailable)
+		return nil, nil, false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		h.logTruncated(r, &sizeMismatchError{Expected: r.ContentLength, Read: counted.n})
+		http.Error(w, "Request body is shorter than the declared Content-Length", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if err != nil {
+		// The Content-Type was checked above, so this is a broken body
+		writeError(w, http.StatusBadRequest, "Malformed multipart body: "+err.Error())
+		return nil, nil, false
+	}
+
+	if h.cfg.DebugLogUploadParts {
+		h.logParts(r)
+	}
+
+	// Get files from form
+	headers := r.MultipartForm.File["file"]
+	if len(headers) == 0 {
+		writeError(w, http.StatusBadRequest, "file field required")
+		return nil, nil, false
+	}
+	// Checked before any part is read or stored
+	if len(headers) > h.cfg.MaxFilesPerRequest {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many files, at most %d per request", h.cfg.MaxFilesPerRequest))
+		return nil, nil, false
+	}
+
+	for _, header := range headers {
+		filename, err := sanitizeFilename(header.Filename)
+		if err != nil {
+			http.Error(w, "Invalid filename: "+err.Error(), http.StatusBadRequest)
+			return nil, nil, false
+		}
+		if err := h.checkExtension(filename); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return nil, nil, false
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			http.Error(w, "Failed to get file", http.StatusBadRequest)
+			return nil, nil, false
+		}
+
+		// Read file content
+		part := h.memory.Reader(file)
+		releases = append(releases, part.Release)
+		content, err := io.ReadAll(part)
+		file.Close()
+		if errors.Is(err, membudget.ErrExhausted) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Server is busy, retry later", http.StatusServiceUnavailable)
+			return nil, nil, false
+		}
+		if err != nil {
+			http.Error(w, "Failed to read file", http.StatusInternalServerError)
+			return nil, nil, false
+		}
+
+		// A part without data is a different mistake than a missing part
+		if len(content) == 0 && h.cfg.RejectEmptyUploads {
+			writeError(w, http.StatusBadRequest, "file is empty: "+filename)
+			return nil, nil, false
+		}
+
+		// Never store a file whose size differs from what the client declared
+		if err := checkDeclaredSize(header, int64(len(content))); err != nil {
+			if mismatch := (*sizeMismatchError)(nil); errors.As(err, &mismatch) {
+				h.logTruncated(r, mismatch)
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, nil, false
+		}
+
+		mimeType := detectMimeType(header.Header.Get("Content-Type"), content)
+		if limit := h.sizeLimit(mimeType); int64(len(content)) > limit {
+			writeTooLarge(w, limit, int64(len(content)))
+			return nil, nil, false
+		}
+
+		us = append(us, upload{
+			Filename: filename,
+			MimeType: mimeType,
+			Content:  content,
+		})
+	}
+	return us, releaseAll, true
+}
+
+// readRawUpload reads the body of r as the content of a single file named
+// by the X-Filename header, which may be percent-encoded for names outside
+// ASCII. Limits and the memory budget apply as for readUpload.
+func (h *Handlers) readRawUpload(w http.ResponseWriter, r *http.Request) (u upload, release func(), ok bool) {
+	raw := r.Header.Get("X-Filename")
+	if raw == "" {
+		writeError(w, http.StatusBadRequest, "X-Filename header required")
+		return upload{}, nil, false
+	}
+	name, err := url.PathUnescape(raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid X-Filename header: "+err.Error())
+		return upload{}, nil, false
+	}
+	filename, err := sanitizeFilename(name)
+	if err != nil {
+		http.Error(w, "Invalid filename: "+err.Error(), http.StatusBadRequest)
+		return upload{}, nil, false
+	}
+	if err := h.checkExtension(filename); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return upload{}, nil, false
+	}
+
+	if r.ContentLength > h.cfg.MaxUploadBytes {
+		writeTooLarge(w, h.cfg.MaxUploadBytes, r.ContentLength)
+		return upload{}, nil, false
+	}
+	body := h.memory.Reader(ctxReadCloser{ctx: r.Context(), ReadCloser: http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadBytes)})
+	content, err := io.ReadAll(body)
+	if err == nil && r.ContentLength >= 0 && int64(len(content)) != r.ContentLength {
+		err = io.ErrUnexpectedEOF
+	}
+	if r.Context().Err() != nil {
+		body.Release()
+		return upload{}, nil, false
+	}
+	if err != nil {
+		body.Release()
+		switch tooLarge := (*http.MaxBytesError)(nil); {
+		case errors.As(err, &tooLarge):
+			writeTooLarge(w, tooLarge.Limit, r.ContentLength)
+		case errors.Is(err, membudget.ErrExhausted):
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Server is busy, retry later", http.StatusServiceUnavailable)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			h.logTruncated(r, &sizeMismatchError{Expected: r.ContentLength, Read: int64(len(content))})
+			http.Error(w, "Request body is shorter than the declared Content-Length", http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to read file", http.StatusBadRequest)
+		}
+		return upload{}, nil, false
+	}
+	if len(content) == 0 && h.cfg.RejectEmptyUploads {
+		body.Release()
+		writeError(w, http.StatusBadRequest, "file is empty: "+filename)
+		return upload{}, nil, false
+	}
+
+	mimeType := detectMimeType(r.Header.Get("Content-Type"), content)
+	if limit := h.sizeLimit(mimeType); int64(len(content)) > limit {
+		body.Release()
+		writeTooLarge(w, limit, int64(len(content)))
+		return upload{}, nil, false
+	}
+
+	return upload{
+		Filename: filename,
+		MimeType: mimeType,
+		Content:  content,
+	}, body.Release, true
+}
+
+// logParts logs the name and size of every part of the parsed multipart
+// form at debug level, never their content. Headers aren't logged at all.
+func (h *Handlers) logParts(r *http.Request) {
+	ctx := r.Context()
+	for field, headers := range r.MultipartForm.File {
+		for _, header := range headers {
+			h.log(ctx).Debug(ctx, "Upload part",
+				slog.String("field", field),
+				slog.String("filename", header.Filename),
+				slog.Int64("size", header.Size),
+			)
+		}
+	}
+	for field, values := range r.MultipartForm.Value {
+		for _, v := range values {
+			h.log(ctx).Debug(ctx, "Upload part",
+				slog.String("field", field),
+				slog.Int("size", len(v)),
+			)
+		}
+	}
+}
+
+// sizeLimit returns the largest accepted file of mimeType, the limit for the
+// exact type, then for its "type/*" wildcard, then MaxUploadBytes. Nothing
+// over storage.MaxDBContentBytes is accepted with the db backend.
+func (h *Handlers) sizeLimit(mimeType string) int64 {
+	limit := h.cfg.MaxUploadBytes
+	base := baseMimeType(mimeType)
+	if l, ok := h.mimeLimits[base]; ok {
+		limit = l
+	} else if major, _, ok := strings.Cut(base, "/"); ok {
+		if l, ok := h.mimeLimits[major+"/*"]; ok {
+			limit = l
+		}
+	}
+	if h.cfg.StorageBackend == config.StorageDB {
+		limit = min(limit, storage.MaxDBContentBytes)
+	}
+	return limit
+}
+
+// logTruncated records an upload rejected for not matching its declared size
+func (h *Handlers) logTruncated(r *http.Request, e *sizeMismatchError) {
+	h.log(r.Context()).Warn(r.Context(), "Rejected upload of unexpected size",
+		slog.Int64("expected_bytes", e.Expected),
+		slog.Int64("read_bytes", e.Read),
+	)
+}
+
+// countingReadCloser counts the bytes read through it
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ctxReadCloser stops reading once ctx is done, so a disconnected client
+// doesn't keep the upload being read
+type ctxReadCloser struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (c ctxReadCloser) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.ReadCloser.Read(p)
+}
+
+// writeTooLarge answers 413 with the upload limit and, when the client
+// declared it, the attempted size
+func writeTooLarge(w http.ResponseWriter, limit, attempted int64) {
+	body := map[string]any{
+		"error":       "Upload exceeds the maximum size of " + strconv.FormatInt(limit, 10) + " bytes",
+		"limit_bytes": limit,
+	}
+	if attempted > 0 {
+		body["attempted_bytes"] = attempted
+	}
+	writeJSON(w, http.StatusRequestEntityTooLarge, body)
+}
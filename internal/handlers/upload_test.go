@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"inv/internal/config"
+	"inv/internal/membudget"
+)
+
+func TestSizeLimit(t *testing.T) {
+	h := &Handlers{
+		cfg:        config.Config{MaxUploadBytes: 1000},
+		mimeLimits: map[string]int64{"text/plain": 10, "image/*": 100, "image/png": 500},
+	}
+	tests := []struct {
+		mimeType string
+		want     int64
+	}{
+		{mimeType: "text/plain", want: 10},
+		{mimeType: "text/plain; charset=utf-8", want: 10},
+		{mimeType: "image/png", want: 500},
+		{mimeType: "image/jpeg", want: 100},
+		{mimeType: "application/pdf", want: 1000},
+		{mimeType: "", want: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mimeType, func(t *testing.T) {
+			if got := h.sizeLimit(tt.mimeType); got != tt.want {
+				t.Errorf("sizeLimit(%q) = %d, want %d", tt.mimeType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadUploadMimeLimit(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	h := &Handlers{
+		cfg: config.Config{
+			MaxUploadBytes:       1 << 20,
+			MultipartMemoryBytes: 1 << 20,
+			MaxFilesPerRequest:   1,
+		},
+		memory:     membudget.New(0),
+		mimeLimits: map[string]int64{"image/png": 64},
+	}
+	tests := []struct {
+		name     string
+		content  []byte
+		want     int
+		wantType string
+	}{
+		{name: "image under its limit", content: append(png, bytes.Repeat([]byte{0}, 32)...), want: http.StatusOK, wantType: "image/png"},
+		{name: "image over its limit", content: append(png, bytes.Repeat([]byte{0}, 100)...), want: http.StatusRequestEntityTooLarge},
+		{name: "unlisted type uses the global limit", content: bytes.Repeat([]byte("a"), 1000), want: http.StatusOK, wantType: "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			u, release, ok := h.readUpload(rec, fileRequest(t, http.MethodPost, "/add", "upload", tt.content))
+			if ok {
+				defer release()
+				rec.WriteHeader(http.StatusOK)
+			}
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d %q, want %d", rec.Code, rec.Body.String(), tt.want)
+			}
+			if ok && u.MimeType != tt.wantType {
+				t.Errorf("mime type = %q, want %q", u.MimeType, tt.wantType)
+			}
+		})
+	}
+}
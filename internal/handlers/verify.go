@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"inv/internal/encryption"
+	"inv/internal/storage"
+)
+
+// verifyResult is the outcome of VerifyFile, stored and computed are only
+// set on a mismatch
+type verifyResult struct {
+	OK       bool   `json:"ok"`
+	Stored   string `json:"stored,omitempty"`
+	Computed string `json:"computed,omitempty"`
+}
+
+// VerifyFile recomputes the SHA-256 of the stored content of the file
+// identified by the {id} path value and compares it to its checksum. A
+// mismatch is answered with VerifyMismatchStatus and flags the row as
+// corrupt, like the background scrub does.
+func (h *Handlers) VerifyFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid file id", http.StatusBadRequest)
+			return
+		}
+
+		f, err := h.lookupFile(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to load file from database", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to load file")
+			return
+		}
+		if !f.checksum.Valid {
+			http.Error(w, "File has no stored checksum", http.StatusUnprocessableEntity)
+			return
+		}
+
+		computed, err := h.hashContent(r.Context(), f)
+		if errors.Is(err, storage.ErrNotFound) {
+			h.writeContentMissing(w, r, id)
+			return
+		}
+		if err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to verify file", slog.String("error", err.Error()))
+			writeDBError(w, err, "Failed to verify file")
+			return
+		}
+
+		if computed == f.checksum.String {
+			writeJSON(w, http.StatusOK, verifyResult{OK: true})
+			return
+		}
+		h.log(r.Context()).Error(r.Context(), "file content does not match checksum",
+			slog.Int64("file_id", id),
+			slog.String("stored", f.checksum.String),
+			slog.String("computed", computed),
+		)
+		if _, err := h.db.ExecContext(r.Context(), `UPDATE files SET corrupt = TRUE WHERE id = $1`, id); err != nil {
+			h.log(r.Context()).Error(r.Context(), "Failed to flag corrupt file", slog.String("error", err.Error()))
+		}
+		writeJSON(w, h.cfg.VerifyMismatchStatus, verifyResult{Stored: f.checksum.String, Computed: computed})
+	}
+}
+
+// hashContent returns the hex SHA-256 of the original bytes of f. Content
+// that no longer decrypts or decompresses is reported as a mismatch with a
+// description instead of a hash, since that's corruption as well.
+func (h *Handlers) hashContent(ctx context.Context, f fileInfo) (string, error) {
+	content, err := h.openContent(ctx, f)
+	if errors.Is(err, encryption.ErrDecrypt) {
+		return "undecryptable", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	src := io.Reader(content)
+	if f.compressed {
+		zr, err := gzip.NewReader(content)
+		if err != nil {
+			return "invalid gzip: " + err.Error(), nil
+		}
+		src = zr
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, src); err != nil {
+		if !f.compressed {
+			return "", err
+		}
+		return "invalid gzip: " + err.Error(), nil
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger is what the middlewares, handlers and background workers log
+// through. FromSlog adapts a *slog.Logger; other logging libraries only
+// need to implement these methods, converting the attrs as they see fit.
+type Logger interface {
+	Debug(ctx context.Context, msg string, attrs ...slog.Attr)
+	Info(ctx context.Context, msg string, attrs ...slog.Attr)
+	Warn(ctx context.Context, msg string, attrs ...slog.Attr)
+	Error(ctx context.Context, msg string, attrs ...slog.Attr)
+
+	// With returns a Logger adding attrs to every record
+	With(attrs ...slog.Attr) Logger
+}
+
+// FromSlog returns a Logger writing to l
+func FromSlog(l *slog.Logger) Logger {
+	return slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debug(ctx context.Context, msg string, attrs ...slog.Attr) {
+	s.l.LogAttrs(ctx, slog.LevelDebug, msg, attrs...)
+}
+
+func (s slogLogger) Info(ctx context.Context, msg string, attrs ...slog.Attr) {
+	s.l.LogAttrs(ctx, slog.LevelInfo, msg, attrs...)
+}
+
+func (s slogLogger) Warn(ctx context.Context, msg string, attrs ...slog.Attr) {
+	s.l.LogAttrs(ctx, slog.LevelWarn, msg, attrs...)
+}
+
+func (s slogLogger) Error(ctx context.Context, msg string, attrs ...slog.Attr) {
+	s.l.LogAttrs(ctx, slog.LevelError, msg, attrs...)
+}
+
+func (s slogLogger) With(attrs ...slog.Attr) Logger {
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	return slogLogger{l: s.l.With(args...)}
+}
+
+// Discard is a Logger dropping everything, e.g. for tools embedding the
+// handlers without wanting their logs
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Debug(context.Context, string, ...slog.Attr) {}
+func (discard) Info(context.Context, string, ...slog.Attr)  {}
+func (discard) Warn(context.Context, string, ...slog.Attr)  {}
+func (discard) Error(context.Context, string, ...slog.Attr) {}
+func (d discard) With(...slog.Attr) Logger                  { return d }
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestFromSlog(t *testing.T) {
+	tests := []struct {
+		name  string
+		log   func(l Logger)
+		level string
+		msg   string
+		attrs map[string]any
+	}{
+		{name: "debug", log: func(l Logger) { l.Debug(context.Background(), "d") }, level: "DEBUG", msg: "d"},
+		{name: "info", log: func(l Logger) { l.Info(context.Background(), "i", slog.Int("n", 1)) }, level: "INFO", msg: "i", attrs: map[string]any{"n": 1.0}},
+		{name: "warn", log: func(l Logger) { l.Warn(context.Background(), "w") }, level: "WARN", msg: "w"},
+		{name: "error", log: func(l Logger) { l.Error(context.Background(), "e", slog.String("error", "boom")) }, level: "ERROR", msg: "e", attrs: map[string]any{"error": "boom"}},
+		{
+			name: "with",
+			log: func(l Logger) {
+				l.With(slog.String("request_id", "r1")).With(slog.Int("n", 2)).Info(context.Background(), "i")
+			},
+			level: "INFO",
+			msg:   "i",
+			attrs: map[string]any{"request_id": "r1", "n": 2.0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(FromSlog(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("decode %q: %v", buf.String(), err)
+			}
+			if got["level"] != tt.level || got["msg"] != tt.msg {
+				t.Errorf("record = %v, want level %s and msg %s", got, tt.level, tt.msg)
+			}
+			for k, v := range tt.attrs {
+				if got[k] != v {
+					t.Errorf("%s = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	l := Discard.With(slog.String("a", "b"))
+	l.Error(context.Background(), "dropped")
+	if l != Discard {
+		t.Error("Discard.With returned a different logger")
+	}
+}
@@ -0,0 +1,78 @@
+package membudget
+
+import (
+	"errors"
+	"expvar"
+	"io"
+	"sync/atomic"
+)
+
+// ErrExhausted is returned by Reader when reading further would exceed the budget
+var ErrExhausted = errors.New("memory budget exhausted")
+
+// usedBytes exposes the bytes currently reserved by all budgets on /metrics
+var usedBytes = expvar.NewInt("upload_memory_bytes")
+
+// Budget is a process-wide ceiling on the bytes requests buffer in memory,
+// a zero limit only tracks usage.
+type Budget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+// New creates a budget of limit bytes
+func New(limit int64) *Budget {
+	return &Budget{limit: limit}
+}
+
+// Used returns the bytes currently reserved
+func (b *Budget) Used() int64 {
+	return b.used.Load()
+}
+
+// reserve takes n bytes from the budget, reporting false when they don't fit
+func (b *Budget) reserve(n int64) bool {
+	if used := b.used.Add(n); b.limit > 0 && used > b.limit {
+		b.used.Add(-n)
+		return false
+	}
+	usedBytes.Add(n)
+	return true
+}
+
+func (b *Budget) release(n int64) {
+	b.used.Add(-n)
+	usedBytes.Add(-n)
+}
+
+// Reader accounts every byte read from the wrapped reader against a budget
+// until Release is called.
+type Reader struct {
+	r io.Reader
+	b *Budget
+	n int64
+}
+
+// Reader wraps r, the caller must call Release once the bytes read are no
+// longer held in memory
+func (b *Budget) Reader(r io.Reader) *Reader {
+	return &Reader{r: r, b: b}
+}
+
+// Read implements io.Reader
+func (t *Reader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if !t.b.reserve(int64(n)) {
+			return 0, ErrExhausted
+		}
+		t.n += int64(n)
+	}
+	return n, err
+}
+
+// Release returns the bytes read so far to the budget
+func (t *Reader) Release() {
+	t.b.release(t.n)
+	t.n = 0
+}
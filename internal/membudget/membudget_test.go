@@ -0,0 +1,59 @@
+package membudget
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		size    int
+		wantErr error
+	}{
+		{name: "within budget", limit: 100, size: 100},
+		{name: "over budget", limit: 100, size: 101, wantErr: ErrExhausted},
+		{name: "unlimited", limit: 0, size: 1 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.limit)
+			r := b.Reader(bytes.NewReader(make([]byte, tt.size)))
+			_, err := io.ReadAll(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadAll error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && b.Used() != int64(tt.size) {
+				t.Errorf("Used = %d, want %d", b.Used(), tt.size)
+			}
+			r.Release()
+			if b.Used() != 0 {
+				t.Errorf("Used after Release = %d, want 0", b.Used())
+			}
+		})
+	}
+}
+
+func TestBudgetSharedByReaders(t *testing.T) {
+	b := New(10)
+	first := b.Reader(bytes.NewReader(make([]byte, 6)))
+	if _, err := io.ReadAll(first); err != nil {
+		t.Fatal(err)
+	}
+
+	second := b.Reader(bytes.NewReader(make([]byte, 6)))
+	if _, err := io.ReadAll(second); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("second reader error = %v, want ErrExhausted", err)
+	}
+	second.Release()
+
+	first.Release()
+	third := b.Reader(bytes.NewReader(make([]byte, 6)))
+	if _, err := io.ReadAll(third); err != nil {
+		t.Errorf("reader after release error = %v, want nil", err)
+	}
+	third.Release()
+}
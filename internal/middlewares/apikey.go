@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"inv/internal/apikeys"
+	"inv/internal/logging"
+)
+
+// APIKeyAuth authenticates requests with a per-client key presented in the
+// X-API-Key header or as an Authorization bearer token. lookup resolves the
+// plaintext key to its principal, returning apikeys.ErrInvalidKey for unknown
+// or revoked keys.
+func APIKeyAuth(logger logging.Logger, lookup func(ctx context.Context, key string) (Principal, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("X-API-Key")
+			if key == "" {
+				key, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			}
+			if key == "" {
+				logger.Warn(r.Context(), "unauthorized access",
+					slog.String("path", r.URL.Path),
+				)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			p, err := lookup(r.Context(), key)
+			if errors.Is(err, apikeys.ErrInvalidKey) {
+				logger.Warn(r.Context(), "invalid api key",
+					slog.String("path", r.URL.Path),
+				)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				logger.Error(r.Context(), "api key lookup failed",
+					slog.String("error", err.Error()),
+				)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
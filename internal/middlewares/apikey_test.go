@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"inv/internal/apikeys"
+	"inv/internal/logging"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	lookup := func(ctx context.Context, key string) (Principal, error) {
+		switch key {
+		case "good":
+			return Principal{Subject: "client-1", Scopes: []string{"files:read"}}, nil
+		case "broken":
+			return Principal{}, errors.New("database down")
+		}
+		return Principal{}, apikeys.ErrInvalidKey
+	}
+	tests := []struct {
+		name    string
+		headers []string
+		status  int
+		subject string
+	}{
+		{name: "no key", status: http.StatusUnauthorized},
+		{name: "x-api-key", headers: []string{"X-API-Key: good"}, status: http.StatusOK, subject: "client-1"},
+		{name: "bearer", headers: []string{"Authorization: Bearer good"}, status: http.StatusOK, subject: "client-1"},
+		{name: "x-api-key wins", headers: []string{"X-API-Key: good", "Authorization: Bearer bad"}, status: http.StatusOK, subject: "client-1"},
+		{name: "not bearer", headers: []string{"Authorization: Basic good"}, status: http.StatusUnauthorized},
+		{name: "unknown key", headers: []string{"X-API-Key: bad"}, status: http.StatusUnauthorized},
+		{name: "lookup failure", headers: []string{"X-API-Key: broken"}, status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(APIKeyAuth(logging.Discard, lookup)(okHandler), newRequest(http.MethodGet, "/files", tt.headers...))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != tt.subject {
+				t.Errorf("subject = %q, want %q", rec.Body.String(), tt.subject)
+			}
+		})
+	}
+}
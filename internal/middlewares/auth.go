@@ -1,39 +1,33 @@
 package middlewares
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"log/slog"
 	"net/http"
+
+	"inv/internal/logging"
 )
 
-// Auth logs request details
-func Auth(logger *slog.Logger, secret string) func(http.Handler) http.Handler {
+// Auth rejects requests whose Authorization header doesn't match the secret.
+// The header is compared in constant time, through its hash so the length
+// doesn't leak either. Holders of the shared secret act as the admin
+// "static" principal.
+func Auth(logger logging.Logger, secret string) func(http.Handler) http.Handler {
+	want := sha256.Sum256([]byte(secret))
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Example: Check Authorization header (simplified)
-			authHeader := r.Header.Get("Authorization")
-			if authHeader != secret { // Replace with real auth logic
-				logger.LogAttrs(r.Context(), slog.LevelWarn, "unauthorized access",
+			got := sha256.Sum256([]byte(r.Header.Get("Authorization")))
+			if subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+				logger.Warn(r.Context(), "unauthorized access",
 					slog.String("path", r.URL.Path),
 				)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
-			next.ServeHTTP(w, r)
+			p := Principal{Subject: "static", Scopes: []string{ScopeAdmin}}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
 		})
 	}
 }
-
-// CORSMiddleware adds basic CORS headers
-func CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
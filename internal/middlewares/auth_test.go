@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+
+	"inv/internal/logging"
+)
+
+func TestAuth(t *testing.T) {
+	const secret = "correct horse battery staple"
+	tests := []struct {
+		name    string
+		headers []string
+		status  int
+		subject string
+	}{
+		{name: "no header", status: http.StatusUnauthorized},
+		{name: "wrong secret", headers: []string{"Authorization: nope"}, status: http.StatusUnauthorized},
+		{name: "prefix of the secret", headers: []string{"Authorization: correct horse"}, status: http.StatusUnauthorized},
+		{name: "secret with a suffix", headers: []string{"Authorization: " + secret + "!"}, status: http.StatusUnauthorized},
+		{name: "secret", headers: []string{"Authorization: " + secret}, status: http.StatusOK, subject: "static"},
+	}
+	h := Auth(logging.Discard, secret)(okHandler)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, newRequest(http.MethodGet, "/files", tt.headers...))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != tt.subject {
+				t.Errorf("subject = %q, want %q", rec.Body.String(), tt.subject)
+			}
+		})
+	}
+}
+
+func TestAuthGrantsAdmin(t *testing.T) {
+	var p Principal
+	h := Auth(logging.Discard, "s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ = PrincipalFrom(r.Context())
+	}))
+	serve(h, newRequest(http.MethodGet, "/", "Authorization: s3cret"))
+	if !p.HasScope(ScopeAdmin) {
+		t.Errorf("scopes = %v, want %q", p.Scopes, ScopeAdmin)
+	}
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+
+	"inv/internal/logging"
+)
+
+func TestBasicAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		pass   string
+		send   bool
+		status int
+	}{
+		{name: "no credentials", status: http.StatusUnauthorized},
+		{name: "valid", user: "admin", pass: "s3cret", send: true, status: http.StatusOK},
+		{name: "wrong password", user: "admin", pass: "s3cre", send: true, status: http.StatusUnauthorized},
+		{name: "wrong user", user: "root", pass: "s3cret", send: true, status: http.StatusUnauthorized},
+		{name: "swapped", user: "s3cret", pass: "admin", send: true, status: http.StatusUnauthorized},
+		{name: "empty", send: true, status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRequest(http.MethodGet, "/files")
+			if tt.send {
+				r.SetBasicAuth(tt.user, tt.pass)
+			}
+			rec := serve(BasicAuth(logging.Discard, "admin", "s3cret")(okHandler), r)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			challenge := rec.Header().Get("WWW-Authenticate")
+			if tt.status == http.StatusUnauthorized && challenge == "" {
+				t.Error("WWW-Authenticate missing on 401")
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != "admin" {
+				t.Errorf("subject = %q, want admin", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestBasicAuthGrantsAdmin(t *testing.T) {
+	var p Principal
+	h := BasicAuth(logging.Discard, "admin", "s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ = PrincipalFrom(r.Context())
+	}))
+	r := newRequest(http.MethodGet, "/")
+	r.SetBasicAuth("admin", "s3cret")
+	serve(h, r)
+	if !p.HasScope(ScopeAdmin) {
+		t.Errorf("scopes = %v, want admin", p.Scopes)
+	}
+}
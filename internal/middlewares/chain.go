@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Chain composes middlewares into one, the first is the outermost so
+// Chain(a, b)(h) is a(b(h))
+func Chain(mws ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// Exempt applies mw to every request except those for the given paths,
+// which go straight to next. Paths match exactly.
+func Exempt(mw func(http.Handler) http.Handler, paths ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(paths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+)
+
+// tag returns a middleware appending name to the X-Order response header
+func tag(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Order", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		name string
+		mws  []func(http.Handler) http.Handler
+		want []string
+	}{
+		{name: "empty", want: nil},
+		{name: "first is outermost", mws: []func(http.Handler) http.Handler{tag("a"), tag("b"), tag("c")}, want: []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(Chain(tt.mws...)(okHandler), newRequest(http.MethodGet, "/"))
+			got := rec.Header().Values("X-Order")
+			if len(got) != len(tt.want) {
+				t.Fatalf("order = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("order = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestExempt(t *testing.T) {
+	deny := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	}
+	h := Exempt(deny, "/healthz", "/")(okHandler)
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/", http.StatusOK},
+		{"/healthz/x", http.StatusUnauthorized},
+		{"/files", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if rec := serve(h, newRequest(http.MethodGet, tt.path)); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies are the networks of proxies whose forwarding headers are
+// believed, nil trusts none
+type TrustedProxies []netip.Prefix
+
+func (t TrustedProxies) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that made r. When the peer is
+// a trusted proxy, X-Forwarded-For is walked right to left, each hop being
+// added by the one after it, and the first address that isn't a trusted
+// proxy is the client. Anything left of it could be forged. X-Real-IP is
+// used when a trusted peer sent no X-Forwarded-For.
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !t.contains(peer) {
+		return host
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	if len(hops) == 0 {
+		if real, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return real.Unmap().String()
+		}
+		return host
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A garbled hop ends the chain, the last good one is as far
+			// as it can be trusted
+			break
+		}
+		client = addr
+		if !t.contains(addr) {
+			break
+		}
+	}
+	return client.Unmap().String()
+}
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := TrustedProxies{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	tests := []struct {
+		name    string
+		proxies TrustedProxies
+		remote  string
+		headers []string
+		want    string
+	}{
+		{name: "direct client", proxies: proxies, remote: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "untrusted peer forging headers", proxies: proxies, remote: "203.0.113.7:5000", headers: []string{"X-Forwarded-For: 1.2.3.4"}, want: "203.0.113.7"},
+		{name: "nothing trusted", remote: "10.0.0.1:5000", headers: []string{"X-Forwarded-For: 1.2.3.4"}, want: "10.0.0.1"},
+		{name: "trusted proxy", proxies: proxies, remote: "10.0.0.1:5000", headers: []string{"X-Forwarded-For: 198.51.100.2"}, want: "198.51.100.2"},
+		{name: "forged hop left of the client", proxies: proxies, remote: "10.0.0.1:5000", headers: []string{"X-Forwarded-For: 1.2.3.4, 198.51.100.2, 10.0.0.2"}, want: "198.51.100.2"},
+		{name: "repeated header", proxies: proxies, remote: "10.0.0.1:5000", headers: []string{"X-Forwarded-For: 1.2.3.4", "X-Forwarded-For: 198.51.100.2"}, want: "198.51.100.2"},
+		{name: "garbled hop", proxies: proxies, remote: "10.0.0.1:5000", headers: []string{"X-Forwarded-For: 198.51.100.2, junk, 10.0.0.2"}, want: "10.0.0.2"},
+		{name: "only trusted hops", proxies: proxies, remote: "10.0.0.1:5000", headers: []string{"X-Forwarded-For: 10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "real ip", proxies: proxies, remote: "10.0.0.1:5000", headers: []string{"X-Real-IP: 198.51.100.9"}, want: "198.51.100.9"},
+		{name: "invalid real ip", proxies: proxies, remote: "10.0.0.1:5000", headers: []string{"X-Real-IP: nope"}, want: "10.0.0.1"},
+		{name: "ipv6 proxy", proxies: proxies, remote: "[::1]:5000", headers: []string{"X-Forwarded-For: 2001:db8::1"}, want: "2001:db8::1"},
+		{name: "mapped ipv4", proxies: proxies, remote: "10.0.0.1:5000", headers: []string{"X-Forwarded-For: ::ffff:198.51.100.2"}, want: "198.51.100.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRequest(http.MethodGet, "/", tt.headers...)
+			r.RemoteAddr = tt.remote
+			if got := tt.proxies.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsMaxAge is how long browsers may cache a preflight response, in seconds
+const corsMaxAge = "600"
+
+// corsAllowHeaders are the request headers the API reads, browsers send
+// anything beyond the CORS-safelisted ones only when a preflight allows it
+var corsAllowHeaders = strings.Join([]string{
+	"Authorization",
+	"Content-Range",
+	"Content-Type",
+	"Idempotency-Key",
+	"If-Match",
+	"X-API-Key",
+	"X-Dry-Run",
+	"X-File-Metadata",
+	"X-Filename",
+	"X-Request-ID",
+}, ", ")
+
+// CORSMiddleware adds basic CORS headers for the allowed origins, "*" allows
+// any origin. Preflight requests are answered with the methods reported for
+// the requested path, 404 when it has none.
+func CORSMiddleware(origins []string, methods func(*http.Request) []string) func(http.Handler) http.Handler {
+	anyOrigin := slices.Contains(origins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else if origin := r.Header.Get("Origin"); slices.Contains(origins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+
+			if r.Method == http.MethodOptions {
+				allowed := methods(r)
+				if len(allowed) == 0 {
+					http.NotFound(w, r)
+					return
+				}
+				allow := strings.Join(append(allowed, http.MethodOptions), ", ")
+				w.Header().Set("Allow", allow)
+				w.Header().Set("Access-Control-Allow-Methods", allow)
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routeMethods are the methods RouteMethods probes for
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// RouteMethods returns the methods mux has a route for at the path of a
+// request, for CORSMiddleware
+func RouteMethods(mux *http.ServeMux) func(*http.Request) []string {
+	return func(r *http.Request) []string {
+		var allowed []string
+		for _, m := range routeMethods {
+			probe := r.Clone(r.Context())
+			probe.Method = m
+			if _, pattern := mux.Handler(probe); pattern != "" {
+				allowed = append(allowed, m)
+			}
+		}
+		return allowed
+	}
+}
@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	methods := func(r *http.Request) []string {
+		if r.URL.Path == "/files" {
+			return []string{http.MethodGet, http.MethodPost}
+		}
+		return nil
+	}
+	tests := []struct {
+		name       string
+		origins    []string
+		method     string
+		path       string
+		origin     string
+		status     int
+		wantOrigin string
+		wantAllow  string
+	}{
+		{name: "any origin", origins: []string{"*"}, method: http.MethodGet, path: "/files", origin: "https://a.example", status: http.StatusOK, wantOrigin: "*"},
+		{name: "listed origin", origins: []string{"https://a.example"}, method: http.MethodGet, path: "/files", origin: "https://a.example", status: http.StatusOK, wantOrigin: "https://a.example"},
+		{name: "unlisted origin", origins: []string{"https://a.example"}, method: http.MethodGet, path: "/files", origin: "https://b.example", status: http.StatusOK},
+		{name: "preflight", origins: []string{"*"}, method: http.MethodOptions, path: "/files", origin: "https://a.example", status: http.StatusNoContent, wantOrigin: "*", wantAllow: "GET, POST, OPTIONS"},
+		{name: "preflight of unknown path", origins: []string{"*"}, method: http.MethodOptions, path: "/nope", status: http.StatusNotFound, wantOrigin: "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := CORSMiddleware(tt.origins, methods)(okHandler)
+			rec := serve(h, newRequest(tt.method, tt.path, "Origin: "+tt.origin))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestCORSPreflightAllowsAPIHeaders(t *testing.T) {
+	h := CORSMiddleware([]string{"*"}, func(*http.Request) []string { return []string{http.MethodPut} })(okHandler)
+	rec := serve(h, newRequest(http.MethodOptions, "/files/1"))
+
+	allowed := strings.Split(rec.Header().Get("Access-Control-Allow-Headers"), ", ")
+	for _, name := range []string{"Authorization", "X-API-Key", "Idempotency-Key", "If-Match", "X-Filename", "Content-Type"} {
+		found := false
+		for _, a := range allowed {
+			found = found || strings.EqualFold(a, name)
+		}
+		if !found {
+			t.Errorf("Access-Control-Allow-Headers = %v, missing %s", allowed, name)
+		}
+	}
+}
+
+func TestRouteMethods(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /files/{id}", okHandler)
+	mux.Handle("DELETE /files/{id}", okHandler)
+	mux.Handle("POST /add", okHandler)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/files/1", "GET, HEAD, DELETE"},
+		{"/add", "POST"},
+		{"/unknown", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := strings.Join(RouteMethods(mux)(newRequest(http.MethodOptions, tt.path)), ", ")
+			if got != tt.want {
+				t.Errorf("methods = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// gzipPool reuses gzip writers across responses
+var gzipPool = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+
+// Gzip compresses responses for clients that accept gzip. Responses that
+// already carry a Content-Encoding, such as compressed file downloads, or a
+// Content-Length, such as other file downloads, and already compressed
+// content types are passed through untouched.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		gw := &gzipWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// gzipWriter decides on the first write whether to compress the response
+type gzipWriter struct {
+	http.ResponseWriter
+	zw          *gzip.Writer // nil when passing through
+	wroteHeader bool
+}
+
+func (g *gzipWriter) WriteHeader(status int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+
+	h := g.Header()
+	if status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" && h.Get("Content-Length") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		g.zw = gzipPool.Get().(*gzip.Writer)
+		g.zw.Reset(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	if !g.wroteHeader {
+		// net/http would sniff the compressed bytes, so sniff the plain ones
+		if g.Header().Get("Content-Type") == "" {
+			g.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.zw == nil {
+		return g.ResponseWriter.Write(p)
+	}
+	return g.zw.Write(p)
+}
+
+// Flush sends buffered compressed data to the client
+func (g *gzipWriter) Flush() {
+	if g.zw != nil {
+		g.zw.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (g *gzipWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// close finishes the gzip stream and returns the writer to the pool
+func (g *gzipWriter) close() {
+	if g.zw == nil {
+		return
+	}
+	g.zw.Close()
+	gzipPool.Put(g.zw)
+	g.zw = nil
+}
+
+// compressible reports whether gzip is likely to shrink contentType
+func compressible(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	for _, prefix := range []string{"image/", "video/", "audio/", "font/woff"} {
+		if strings.HasPrefix(ct, prefix) && !strings.HasPrefix(ct, "image/svg") {
+			return false
+		}
+	}
+	for _, t := range []string{"application/zip", "application/gzip", "application/x-gzip", "application/zstd", "application/x-7z-compressed", "application/x-rar-compressed", "application/pdf"} {
+		if strings.HasPrefix(ct, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptsGzip reports whether the client accepts a gzip Content-Encoding
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		return strings.ReplaceAll(params, " ", "") != "q=0"
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	body := strings.Repeat(`{"id": 1}`, 100)
+	tests := []struct {
+		name     string
+		method   string
+		accept   string
+		header   map[string]string
+		compress bool
+	}{
+		{name: "json", method: http.MethodGet, accept: "gzip", header: map[string]string{"Content-Type": "application/json"}, compress: true},
+		{name: "sniffed text", method: http.MethodGet, accept: "gzip, deflate", compress: true},
+		{name: "client without gzip", method: http.MethodGet, accept: "br", header: map[string]string{"Content-Type": "application/json"}},
+		{name: "gzip refused", method: http.MethodGet, accept: "gzip;q=0", header: map[string]string{"Content-Type": "application/json"}},
+		{name: "head", method: http.MethodHead, accept: "gzip", header: map[string]string{"Content-Type": "application/json"}},
+		{name: "image", method: http.MethodGet, accept: "gzip", header: map[string]string{"Content-Type": "image/png"}},
+		{name: "svg", method: http.MethodGet, accept: "gzip", header: map[string]string{"Content-Type": "image/svg+xml"}, compress: true},
+		{name: "already encoded", method: http.MethodGet, accept: "gzip", header: map[string]string{"Content-Type": "text/plain", "Content-Encoding": "gzip"}},
+		{name: "content length set", method: http.MethodGet, accept: "gzip", header: map[string]string{"Content-Type": "text/plain", "Content-Length": "900"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				io.WriteString(w, body)
+			}))
+			rec := serve(h, newRequest(tt.method, "/files", "Accept-Encoding: "+tt.accept))
+
+			compressed := rec.Header().Get("Content-Encoding") == "gzip" && tt.header["Content-Encoding"] == ""
+			if compressed != tt.compress {
+				t.Fatalf("compressed = %v, want %v", compressed, tt.compress)
+			}
+			if !compressed {
+				if got := rec.Body.String(); got != body {
+					t.Errorf("body changed without compression: %q", got)
+				}
+				return
+			}
+			if rec.Header().Get("Content-Length") != "" {
+				t.Errorf("Content-Length = %q on a compressed response", rec.Header().Get("Content-Length"))
+			}
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != body {
+				t.Errorf("decompressed body = %q, want %q", got, body)
+			}
+		})
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip; q=0", false},
+		{"gzip;q=0", false},
+		{"br, identity", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := acceptsGzip(newRequest(http.MethodGet, "/", "Accept-Encoding: "+tt.header)); got != tt.want {
+				t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
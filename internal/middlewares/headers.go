@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+
+	"inv/internal/logging"
+)
+
+// HeaderLimits rejects requests carrying more than maxCount header fields or
+// more than maxBytes of them, counted as name, value and the separators of
+// each line, with 431. Either limit is disabled when zero. The server's
+// MaxHeaderBytes still applies first, this bounds requests well below it.
+func HeaderLimits(logger logging.Logger, maxBytes, maxCount int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var size, count int
+			for name, values := range r.Header {
+				for _, v := range values {
+					size += len(name) + len(v) + len(": \r\n")
+				}
+				count += len(values)
+			}
+			if (maxBytes > 0 && size > maxBytes) || (maxCount > 0 && count > maxCount) {
+				logger.Warn(r.Context(), "request headers too large",
+					slog.String("path", r.URL.Path),
+					slog.Int("bytes", size),
+					slog.Int("count", count),
+				)
+				http.Error(w, "Request header fields too large", http.StatusRequestHeaderFieldsTooLarge)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
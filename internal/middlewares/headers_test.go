@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"inv/internal/logging"
+)
+
+func TestHeaderLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int
+		maxCount int
+		headers  []string
+		status   int
+	}{
+		{name: "within limits", maxBytes: 1024, maxCount: 5, headers: []string{"X-A: 1", "X-B: 2"}, status: http.StatusOK},
+		{name: "too many fields", maxBytes: 1024, maxCount: 2, headers: []string{"X-A: 1", "X-B: 2", "X-C: 3"}, status: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "repeated field counts each value", maxCount: 2, headers: []string{"X-A: 1", "X-A: 2", "X-A: 3"}, status: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "too many bytes", maxBytes: 64, headers: []string{"X-A: " + strings.Repeat("a", 64)}, status: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "limits disabled", headers: []string{"X-A: " + strings.Repeat("a", 1<<12), "X-B: 1", "X-C: 1"}, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := HeaderLimits(logging.Discard, tt.maxBytes, tt.maxCount)(okHandler)
+			if rec := serve(h, newRequest(http.MethodGet, "/", tt.headers...)); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
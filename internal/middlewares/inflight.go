@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// InFlight counts the requests currently being served, so shutdown can
+// report how many it is still waiting for.
+type InFlight struct {
+	n atomic.Int64
+}
+
+// Track counts requests passing through next
+func (f *InFlight) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.n.Add(1)
+		defer f.n.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Count returns the number of requests in flight
+func (f *InFlight) Count() int64 {
+	return f.n.Load()
+}
@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestInFlight(t *testing.T) {
+	var f InFlight
+	var during int64
+	h := f.Track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		during = f.Count()
+	}))
+	serve(h, newRequest(http.MethodGet, "/"))
+
+	if during != 1 {
+		t.Errorf("Count during the request = %d, want 1", during)
+	}
+	if got := f.Count(); got != 0 {
+		t.Errorf("Count after the request = %d, want 0", got)
+	}
+}
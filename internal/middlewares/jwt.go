@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"inv/internal/logging"
+)
+
+// JWTAuth authenticates requests carrying an HS256 signed bearer token.
+// Expired or not-yet-valid tokens are rejected and the subject and
+// space separated scope claims are stored in the request context as the
+// Principal.
+func JWTAuth(logger logging.Logger, secret string) func(http.Handler) http.Handler {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	keyFunc := func(*jwt.Token) (any, error) { return []byte(secret), nil }
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || raw == "" {
+				logger.Warn(r.Context(), "unauthorized access",
+					slog.String("path", r.URL.Path),
+				)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			var claims tokenClaims
+			if _, err := parser.ParseWithClaims(raw, &claims, keyFunc); err != nil {
+				logger.Warn(r.Context(), "invalid token",
+					slog.String("path", r.URL.Path),
+					slog.String("error", err.Error()),
+				)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			p := Principal{Subject: claims.Subject, Scopes: strings.Fields(claims.Scope)}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// tokenClaims are the registered claims plus the OAuth style scope claim
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+}
@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"inv/internal/logging"
+)
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+// signToken returns claims signed with method and key
+func signToken(t *testing.T, method jwt.SigningMethod, key any, claims tokenClaims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWTAuth(t *testing.T) {
+	now := time.Now()
+	valid := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))},
+		Scope:            "files:read files:write",
+	}
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+	notYet := valid
+	notYet.NotBefore = jwt.NewNumericDate(now.Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		scopes []string
+	}{
+		{name: "no header", status: http.StatusUnauthorized},
+		{name: "empty bearer", header: "Bearer ", status: http.StatusUnauthorized},
+		{name: "not bearer", header: "Basic " + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), valid), status: http.StatusUnauthorized},
+		{name: "garbage", header: "Bearer not.a.token", status: http.StatusUnauthorized},
+		{name: "valid", header: "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), valid), status: http.StatusOK, scopes: []string{"files:read", "files:write"}},
+		{name: "wrong secret", header: "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("another secret of the same size!"), valid), status: http.StatusUnauthorized},
+		{name: "other hmac", header: "Bearer " + signToken(t, jwt.SigningMethodHS512, []byte(testJWTSecret), valid), status: http.StatusUnauthorized},
+		{name: "alg none", header: "Bearer " + signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid), status: http.StatusUnauthorized},
+		{name: "expired", header: "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), expired), status: http.StatusUnauthorized},
+		{name: "not yet valid", header: "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), notYet), status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Principal
+			h := JWTAuth(logging.Discard, testJWTSecret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = PrincipalFrom(r.Context())
+			}))
+			r := newRequest(http.MethodGet, "/files")
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			rec := serve(h, r)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got.Subject != "user-1" {
+				t.Errorf("subject = %q, want user-1", got.Subject)
+			}
+			for _, s := range tt.scopes {
+				if !got.HasScope(s) {
+					t.Errorf("scopes = %v, missing %s", got.Scopes, s)
+				}
+			}
+			if got.HasScope(ScopeAdmin) {
+				t.Error("token without the admin scope granted admin")
+			}
+		})
+	}
+}
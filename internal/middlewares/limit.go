@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"inv/internal/logging"
+)
+
+// ConcurrencyLimit bounds the number of in-flight requests to max using a
+// buffered channel as semaphore. A request arriving at capacity waits up to
+// wait for a slot (zero rejects immediately) and then gets 503 with
+// Retry-After. A zero max disables the limit.
+func ConcurrencyLimit(logger logging.Logger, max int, wait time.Duration) func(http.Handler) http.Handler {
+	if max <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	sem := make(chan struct{}, max)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acquire(r, sem, wait) {
+				logger.Warn(r.Context(), "concurrency limit reached",
+					slog.String("path", r.URL.Path),
+					slog.Int("limit", max),
+				)
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many concurrent requests, retry later", http.StatusServiceUnavailable)
+				return
+			}
+			defer func() { <-sem }()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// acquire takes a slot from sem, waiting up to wait or until the request is canceled
+func acquire(r *http.Request, sem chan struct{}, wait time.Duration) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
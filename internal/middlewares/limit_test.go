@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"inv/internal/logging"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		max    int
+		wait   time.Duration
+		status int
+	}{
+		{name: "disabled", max: 0, status: http.StatusOK},
+		{name: "free slot", max: 2, status: http.StatusOK},
+		{name: "full without wait", max: 1, status: http.StatusServiceUnavailable},
+		{name: "full after wait", max: 1, wait: 10 * time.Millisecond, status: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/block" {
+					close(started)
+					<-release
+				}
+			})
+			h := ConcurrencyLimit(logging.Discard, tt.max, tt.wait)(blocking)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				serve(h, newRequest(http.MethodGet, "/block"))
+			}()
+			<-started
+			rec := serve(h, newRequest(http.MethodGet, "/"))
+			close(release)
+			<-done
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+				t.Error("Retry-After missing on a rejected request")
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimitReleasesSlots(t *testing.T) {
+	h := ConcurrencyLimit(logging.Discard, 1, 0)(okHandler)
+	for i := 0; i < 3; i++ {
+		if rec := serve(h, newRequest(http.MethodGet, "/")); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i, rec.Code)
+		}
+	}
+}
+
+func TestConcurrencyLimitWaitsForSlot(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := ConcurrencyLimit(logging.Discard, 1, time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			close(started)
+			<-release
+		}
+	}))
+
+	go serve(h, newRequest(http.MethodGet, "/block"))
+	<-started
+	time.AfterFunc(10*time.Millisecond, func() { close(release) })
+	if rec := serve(h, newRequest(http.MethodGet, "/")); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 once the slot frees up", rec.Code)
+	}
+}
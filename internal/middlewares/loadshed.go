@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"inv/internal/logging"
+)
+
+// LoadShedder rejects requests with 503 while the database pool is saturated.
+// Requests are shed when the pool has at least maxInUse open connections in
+// use, or when at least maxWaitCount callers had to wait for a connection
+// during the last window. A zero threshold disables the corresponding check.
+// The wait count is sampled by Run, once per window.
+type LoadShedder struct {
+	logger       logging.Logger
+	stats        func() sql.DBStats
+	maxInUse     int
+	maxWaitCount int64
+	window       time.Duration
+
+	lastWaitCount int64
+	waited        atomic.Int64
+}
+
+// NewLoadShedder creates a LoadShedder reading the pool state from stats
+func NewLoadShedder(logger logging.Logger, stats func() sql.DBStats, maxInUse int, maxWaitCount int64, window time.Duration) *LoadShedder {
+	return &LoadShedder{
+		logger:        logger,
+		stats:         stats,
+		maxInUse:      maxInUse,
+		maxWaitCount:  maxWaitCount,
+		window:        window,
+		lastWaitCount: stats().WaitCount,
+	}
+}
+
+// Run samples the wait count every window until ctx is canceled. It returns
+// at once when the wait count check is disabled.
+func (l *LoadShedder) Run(ctx context.Context) {
+	if l.maxWaitCount <= 0 || l.window <= 0 {
+		return
+	}
+	ticker := time.NewTicker(l.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.sample()
+		}
+	}
+}
+
+// sample records the waits since the previous sample as the last window's
+func (l *LoadShedder) sample() {
+	count := l.stats().WaitCount
+	l.waited.Store(count - l.lastWaitCount)
+	l.lastWaitCount = count
+}
+
+// Shed wraps next, shedding its requests while the pool is saturated
+func (l *LoadShedder) Shed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inUse := l.stats().InUse
+		waited := l.waited.Load()
+
+		if (l.maxInUse > 0 && inUse >= l.maxInUse) || (l.maxWaitCount > 0 && waited >= l.maxWaitCount) {
+			l.logger.Warn(r.Context(), "shedding request under database load",
+				slog.String("path", r.URL.Path),
+				slog.Int("in_use", inUse),
+				slog.Int64("waited", waited),
+			)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,115 @@
+package middlewares
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"inv/internal/logging"
+)
+
+func TestLoadShedder(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxInUse     int
+		maxWaitCount int64
+		before       sql.DBStats
+		during       sql.DBStats
+		status       int
+	}{
+		{name: "idle pool", maxInUse: 5, maxWaitCount: 3, during: sql.DBStats{InUse: 1}, status: http.StatusOK},
+		{name: "pool saturated", maxInUse: 5, during: sql.DBStats{InUse: 5}, status: http.StatusServiceUnavailable},
+		{name: "callers waiting", maxWaitCount: 3, before: sql.DBStats{WaitCount: 10}, during: sql.DBStats{WaitCount: 13}, status: http.StatusServiceUnavailable},
+		{name: "old waits ignored", maxWaitCount: 3, before: sql.DBStats{WaitCount: 10}, during: sql.DBStats{WaitCount: 12}, status: http.StatusOK},
+		{name: "checks disabled", during: sql.DBStats{InUse: 100, WaitCount: 100}, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := tt.before
+			l := NewLoadShedder(logging.Discard, func() sql.DBStats { return st }, tt.maxInUse, tt.maxWaitCount, time.Second)
+			st = tt.during
+			l.sample()
+
+			rec := serve(l.Shed(okHandler), newRequest(http.MethodGet, "/"))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+				t.Error("Retry-After missing on a shed request")
+			}
+		})
+	}
+}
+
+func TestLoadShedderCountsWaitsPerWindow(t *testing.T) {
+	st := sql.DBStats{}
+	l := NewLoadShedder(logging.Discard, func() sql.DBStats { return st }, 0, 3, time.Second)
+	h := l.Shed(okHandler)
+
+	// Requests within a window all see its waits, however many there are
+	st.WaitCount = 3
+	l.sample()
+	for range 5 {
+		if rec := serve(h, newRequest(http.MethodGet, "/")); rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status = %d, want 503 for the whole window", rec.Code)
+		}
+	}
+
+	l.sample()
+	if rec := serve(h, newRequest(http.MethodGet, "/")); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 after a window without waits", rec.Code)
+	}
+}
+
+func TestLoadShedderRun(t *testing.T) {
+	var st sql.DBStats
+	stats := make(chan sql.DBStats, 1)
+	stats <- st
+	l := NewLoadShedder(logging.Discard, func() sql.DBStats {
+		select {
+		case st = <-stats:
+		default:
+		}
+		return st
+	}, 0, 3, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		l.Run(ctx)
+		close(done)
+	}()
+
+	stats <- sql.DBStats{WaitCount: 5}
+	deadline := time.Now().Add(5 * time.Second)
+	for l.waited.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("Run never sampled the wait count")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestLoadShedderOnlyShedsWrappedRoutes(t *testing.T) {
+	l := NewLoadShedder(logging.Discard, func() sql.DBStats { return sql.DBStats{InUse: 10} }, 5, 0, time.Second)
+	mux := http.NewServeMux()
+	mux.Handle("POST /add", l.Shed(okHandler))
+	mux.Handle("GET /files/{id}", okHandler)
+
+	tests := []struct {
+		method, target string
+		status         int
+	}{
+		{method: http.MethodPost, target: "/add", status: http.StatusServiceUnavailable},
+		{method: http.MethodGet, target: "/files/1", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serve(mux, newRequest(tt.method, tt.target)); rec.Code != tt.status {
+			t.Errorf("%s %s status = %d, want %d", tt.method, tt.target, rec.Code, tt.status)
+		}
+	}
+}
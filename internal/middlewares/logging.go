@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"inv/internal/logging"
+)
+
+type routeKey struct{}
+
+// LoggingMiddleware logs request details and stores a logger carrying the
+// request id, echoed in X-Request-ID, for LoggerFrom. The client address is
+// worked out with proxies, see TrustedProxies.ClientIP.
+func LoggingMiddleware(logger logging.Logger, proxies TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := requestID(r.Header.Get("X-Request-ID"))
+			w.Header().Set("X-Request-ID", id)
+
+			// Everything logged for this request carries its id and client
+			reqLogger := logger.With(
+				slog.String("request_id", id),
+				slog.String("remote_addr", proxies.ClientIP(r)),
+			)
+			ctx := WithLogger(r.Context(), reqLogger)
+
+			route := new(string)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, routeKey{}, route)))
+			reqLogger.Info(ctx, "request completed",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", *route),
+				slog.String("user_agent", r.UserAgent()),
+				slog.Duration("duration", time.Since(start)),
+			)
+		})
+	}
+}
+
+// CaptureRoute records the route pattern matched by mux, e.g. /files/{id},
+// for LoggingMiddleware. It has to wrap the mux directly since middlewares
+// in between may replace the request the mux stores the pattern on.
+func CaptureRoute(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			// Drop the method from patterns like "GET /files/{id}"
+			_, path, found := strings.Cut(r.Pattern, " ")
+			if !found {
+				path = r.Pattern
+			}
+			*route = path
+		}
+	})
+}
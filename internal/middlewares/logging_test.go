@@ -0,0 +1,121 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"inv/internal/logging"
+)
+
+// recordLogger keeps the messages and attrs logged through it
+type recordLogger struct {
+	mu      *sync.Mutex
+	attrs   []slog.Attr
+	records *[]map[string]string
+}
+
+func newRecordLogger() recordLogger {
+	return recordLogger{mu: new(sync.Mutex), records: new([]map[string]string)}
+}
+
+func (l recordLogger) log(msg string, attrs []slog.Attr) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec := map[string]string{"msg": msg}
+	for _, a := range append(l.attrs, attrs...) {
+		rec[a.Key] = a.Value.String()
+	}
+	*l.records = append(*l.records, rec)
+}
+
+func (l recordLogger) Debug(_ context.Context, msg string, attrs ...slog.Attr) { l.log(msg, attrs) }
+func (l recordLogger) Info(_ context.Context, msg string, attrs ...slog.Attr)  { l.log(msg, attrs) }
+func (l recordLogger) Warn(_ context.Context, msg string, attrs ...slog.Attr)  { l.log(msg, attrs) }
+func (l recordLogger) Error(_ context.Context, msg string, attrs ...slog.Attr) { l.log(msg, attrs) }
+
+func (l recordLogger) With(attrs ...slog.Attr) logging.Logger {
+	l.attrs = append(append([]slog.Attr(nil), l.attrs...), attrs...)
+	return l
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "client id kept", header: "abc-123", keep: true},
+		{name: "missing id generated"},
+		{name: "unprintable id replaced", header: "abc\x00"},
+		{name: "spaces replaced", header: "a b"},
+		{name: "oversized id replaced", header: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "longest id kept", header: strings.Repeat("a", maxRequestIDLength), keep: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := newRecordLogger()
+			var fromCtx bool
+			h := LoggingMiddleware(logger, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, fromCtx = LoggerFrom(r.Context())
+			}))
+			r := newRequest(http.MethodGet, "/files")
+			if tt.header != "" {
+				r.Header.Set("X-Request-ID", tt.header)
+			}
+			r.RemoteAddr = "203.0.113.7:5000"
+			rec := serve(h, r)
+
+			id := rec.Header().Get("X-Request-ID")
+			if tt.keep && id != tt.header {
+				t.Errorf("X-Request-ID = %q, want %q", id, tt.header)
+			}
+			if !tt.keep && (id == tt.header || len(id) != 32) {
+				t.Errorf("X-Request-ID = %q, want a generated id", id)
+			}
+			if !fromCtx {
+				t.Error("LoggerFrom found no logger in the handler")
+			}
+			if len(*logger.records) != 1 {
+				t.Fatalf("logged %d records, want 1", len(*logger.records))
+			}
+			got := (*logger.records)[0]
+			if got["request_id"] != id || got["remote_addr"] != "203.0.113.7" || got["path"] != "/files" {
+				t.Errorf("record = %v", got)
+			}
+		})
+	}
+}
+
+func TestCaptureRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /files/{id}", okHandler)
+	mux.Handle("/healthz", okHandler)
+
+	tests := []struct {
+		path  string
+		route string
+	}{
+		{"/files/42", "/files/{id}"},
+		{"/healthz", "/healthz"},
+		{"/unknown", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			logger := newRecordLogger()
+			serve(LoggingMiddleware(logger, nil)(CaptureRoute(mux)), newRequest(http.MethodGet, tt.path))
+			if got := (*logger.records)[0]["route"]; got != tt.route {
+				t.Errorf("route = %q, want %q", got, tt.route)
+			}
+		})
+	}
+}
+
+func TestLoggerFromEmpty(t *testing.T) {
+	if _, ok := LoggerFrom(context.Background()); ok {
+		t.Error("LoggerFrom found a logger in an empty context")
+	}
+}
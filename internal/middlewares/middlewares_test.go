@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// okHandler answers 200 with the subject of the request's principal, if any
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	p, _ := PrincipalFrom(r.Context())
+	w.Write([]byte(p.Subject))
+})
+
+// serve runs h for a request built by newRequest and returns the response
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+// newRequest returns a request with the given headers, as "Name: value" pairs
+func newRequest(method, target string, headers ...string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	for _, h := range headers {
+		name, value, _ := strings.Cut(h, ": ")
+		r.Header.Add(name, value)
+	}
+	return r
+}
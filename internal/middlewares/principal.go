@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"inv/internal/logging"
+)
+
+// ScopeAdmin grants access to administrative endpoints
+const ScopeAdmin = "admin"
+
+// Principal identifies the authenticated caller of a request
+type Principal struct {
+	Subject string
+	Scopes  []string
+}
+
+// HasScope reports whether the principal was granted scope
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored by the auth middlewares, if any
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// RequireScope rejects requests whose principal lacks scope with 403
+func RequireScope(logger logging.Logger, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, _ := PrincipalFrom(r.Context())
+			if !p.HasScope(scope) {
+				logger.Warn(r.Context(), "forbidden access",
+					slog.String("path", r.URL.Path),
+					slog.String("subject", p.Subject),
+					slog.String("scope", scope),
+				)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
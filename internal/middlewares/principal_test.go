@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"inv/internal/logging"
+)
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *Principal
+		status    int
+	}{
+		{name: "no principal", status: http.StatusForbidden},
+		{name: "missing scope", principal: &Principal{Subject: "u", Scopes: []string{"files:read"}}, status: http.StatusForbidden},
+		{name: "granted scope", principal: &Principal{Subject: "u", Scopes: []string{"files:read", ScopeAdmin}}, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRequest(http.MethodGet, "/admin")
+			if tt.principal != nil {
+				r = r.WithContext(WithPrincipal(r.Context(), *tt.principal))
+			}
+			if rec := serve(RequireScope(logging.Discard, ScopeAdmin)(okHandler), r); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestPrincipalFrom(t *testing.T) {
+	if _, ok := PrincipalFrom(context.Background()); ok {
+		t.Error("PrincipalFrom found a principal in an empty context")
+	}
+	want := Principal{Subject: "u", Scopes: []string{"a"}}
+	got, ok := PrincipalFrom(WithPrincipal(context.Background(), want))
+	if !ok || got.Subject != want.Subject || !got.HasScope("a") || got.HasScope("b") {
+		t.Errorf("PrincipalFrom = %+v, %v, want %+v", got, ok, want)
+	}
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"inv/internal/logging"
+)
+
+// maxStackBytes caps the stack trace attached to panic logs
+const maxStackBytes = 8 << 10
+
+// PanicHook is told about a recovered panic after it's logged, e.g. to raise
+// an alert. It runs on the request goroutine, so it must not block.
+type PanicHook func(ctx context.Context, recovered any, stack []byte)
+
+// RecoveryMiddleware recovers from panics and logs them with the stack trace,
+// then calls onPanic unless it's nil. http.ErrAbortHandler is re-panicked so
+// net/http can abort the response.
+func RecoveryMiddleware(logger logging.Logger, onPanic PanicHook) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					if err == http.ErrAbortHandler {
+						panic(err)
+					}
+					stack := debug.Stack()
+					if len(stack) > maxStackBytes {
+						stack = stack[:maxStackBytes]
+					}
+					logger.Error(r.Context(), "panic recovered",
+						slog.Any("error", err),
+						slog.String("stack", string(stack)),
+					)
+					if onPanic != nil {
+						callPanicHook(r.Context(), logger, onPanic, err, stack)
+					}
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// callPanicHook runs hook, logging rather than propagating a panic in it
+func callPanicHook(ctx context.Context, logger logging.Logger, hook PanicHook, recovered any, stack []byte) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error(ctx, "panic hook panicked", slog.Any("error", err))
+		}
+	}()
+	hook(ctx, recovered, stack)
+}
@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"inv/internal/logging"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	panicking := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") })
+	tests := []struct {
+		name    string
+		handler http.Handler
+		hook    func(t *testing.T, called *bool) PanicHook
+		status  int
+		called  bool
+	}{
+		{name: "no panic", handler: okHandler, status: http.StatusOK},
+		{name: "panic without hook", handler: panicking, status: http.StatusInternalServerError},
+		{
+			name:    "panic with hook",
+			handler: panicking,
+			hook: func(t *testing.T, called *bool) PanicHook {
+				return func(ctx context.Context, recovered any, stack []byte) {
+					*called = true
+					if recovered != "boom" {
+						t.Errorf("recovered = %v, want boom", recovered)
+					}
+					if len(stack) == 0 || len(stack) > maxStackBytes {
+						t.Errorf("stack length = %d, want 1..%d", len(stack), maxStackBytes)
+					}
+				}
+			},
+			status: http.StatusInternalServerError,
+			called: true,
+		},
+		{
+			name:    "panicking hook",
+			handler: panicking,
+			hook: func(t *testing.T, called *bool) PanicHook {
+				return func(context.Context, any, []byte) {
+					*called = true
+					panic("hook")
+				}
+			},
+			status: http.StatusInternalServerError,
+			called: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			var hook PanicHook
+			if tt.hook != nil {
+				hook = tt.hook(t, &called)
+			}
+			rec := serve(RecoveryMiddleware(logging.Discard, hook)(tt.handler), newRequest(http.MethodGet, "/"))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if called != tt.called {
+				t.Errorf("hook called = %v, want %v", called, tt.called)
+			}
+		})
+	}
+}
+
+func TestRecoveryMiddlewareRepanicsAbort(t *testing.T) {
+	h := RecoveryMiddleware(logging.Discard, func(context.Context, any, []byte) {
+		t.Error("hook called for http.ErrAbortHandler")
+	})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) }))
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	serve(h, newRequest(http.MethodGet, "/"))
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"inv/internal/logging"
+)
+
+// maxRequestIDLength bounds request ids taken from the X-Request-ID header
+const maxRequestIDLength = 128
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying the request-scoped logger
+func WithLogger(ctx context.Context, logger logging.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFrom returns the logger stored by LoggingMiddleware, which carries
+// the request id and client address, if any
+func LoggerFrom(ctx context.Context) (logging.Logger, bool) {
+	l, ok := ctx.Value(loggerKey{}).(logging.Logger)
+	return l, ok
+}
+
+// requestID keeps a sane id set by the client or a proxy, so logs can be
+// correlated across services, and generates one otherwise
+func requestID(header string) string {
+	if header != "" && len(header) <= maxRequestIDLength && printable(header) {
+		return header
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// printable reports whether s is visible ASCII only, anything else could
+// forge log lines or headers
+func printable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x21 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+package middlewares
+
+import "net/http"
+
+// SecurityHeaders sets the given hardening headers on every response, empty
+// values are skipped. Handlers can still override them since they're set
+// before next runs.
+func SecurityHeaders(headers map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, value := range headers {
+				if value != "" {
+					w.Header().Set(name, value)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
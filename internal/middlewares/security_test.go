@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	headers := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Content-Security-Policy": "",
+	}
+	override := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	})
+	tests := []struct {
+		name    string
+		handler http.Handler
+		want    map[string]string
+	}{
+		{
+			name:    "set",
+			handler: okHandler,
+			want:    map[string]string{"X-Content-Type-Options": "nosniff", "X-Frame-Options": "DENY", "Content-Security-Policy": ""},
+		},
+		{
+			name:    "overridden by handler",
+			handler: override,
+			want:    map[string]string{"X-Content-Type-Options": "nosniff", "X-Frame-Options": "SAMEORIGIN"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(SecurityHeaders(headers)(tt.handler), newRequest(http.MethodGet, "/"))
+			for name, want := range tt.want {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if _, ok := rec.Header()["Content-Security-Policy"]; ok {
+				t.Error("empty header value was set")
+			}
+		})
+	}
+}
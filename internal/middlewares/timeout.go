@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"inv/internal/logging"
+)
+
+// Timeout bounds each request by d through its context, so database queries
+// and storage calls give up once it passes. A request that times out before
+// anything was written gets 504, otherwise the response is just cut short.
+// A zero d disables the timeout.
+//
+// Unlike http.TimeoutHandler the response isn't buffered, so it's fine for
+// streaming handlers.
+func Timeout(logger logging.Logger, d time.Duration) func(http.Handler) http.Handler {
+	if d <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{ResponseWriter: w}
+			next.ServeHTTP(tw, r.WithContext(ctx))
+
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return
+			}
+			logger.Warn(r.Context(), "request timed out",
+				slog.String("path", r.URL.Path),
+				slog.Duration("timeout", d),
+			)
+			if !tw.wrote {
+				http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+			}
+		})
+	}
+}
+
+// timeoutWriter records whether the handler started the response
+type timeoutWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
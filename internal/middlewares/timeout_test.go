@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"inv/internal/logging"
+)
+
+func TestTimeout(t *testing.T) {
+	// waitDeadline blocks until the request's context expires, like a slow query
+	waitDeadline := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		handler http.HandlerFunc
+		status  int
+		body    string
+	}{
+		{name: "fast handler", timeout: time.Second, handler: okHandler, status: http.StatusOK},
+		{name: "timed out before writing", timeout: 10 * time.Millisecond, handler: waitDeadline, status: http.StatusGatewayTimeout, body: "Request timed out\n"},
+		{
+			name:    "timed out after writing",
+			timeout: 10 * time.Millisecond,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("partial"))
+				waitDeadline(w, r)
+			},
+			status: http.StatusOK,
+			body:   "partial",
+		},
+		{
+			name:    "disabled",
+			timeout: 0,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := r.Context().Deadline(); ok {
+					t.Error("deadline set on the request with the timeout disabled")
+				}
+			},
+			status: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(Timeout(logging.Discard, tt.timeout)(tt.handler), newRequest(http.MethodGet, "/"))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Body.String(); got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
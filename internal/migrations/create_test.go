@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCreateDatabaseRejectsURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "key value form", url: "host=localhost dbname=filedb", want: "must be a postgres:// URL"},
+		{name: "no database", url: "postgres://user@localhost:5432/", want: "names no database"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CreateDatabase(context.Background(), "postgres", tt.url)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CreateDatabase(%q) error = %v, want %q", tt.url, err, tt.want)
+			}
+		})
+	}
+}
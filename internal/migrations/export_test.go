@@ -0,0 +1,4 @@
+package migrations
+
+// Files exposes the embedded migrations to the external tests
+var Files = files
@@ -0,0 +1,68 @@
+package migrations_test
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"testing"
+
+	"inv/internal/migrations"
+	"inv/internal/testdb"
+)
+
+// migrationName is NNNN_description.sql
+var migrationName = regexp.MustCompile(`^\d{4}_[a-z0-9_]+\.sql$`)
+
+func TestMigrationNames(t *testing.T) {
+	names, err := fs.Glob(migrations.Files, "sql/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	sort.Strings(names)
+	seen := map[string]bool{}
+	for i, name := range names {
+		base := name[len("sql/"):]
+		if !migrationName.MatchString(base) {
+			t.Errorf("%s doesn't match NNNN_description.sql", base)
+			continue
+		}
+		version := base[:4]
+		if seen[version] {
+			t.Errorf("version %s is used twice", version)
+		}
+		seen[version] = true
+		if want := fmt.Sprintf("%04d", i+1); version != want {
+			t.Errorf("%s: want version %s, versions must be sequential", base, want)
+		}
+	}
+}
+
+func TestRunIsIdempotent(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	var before int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM schema_migrations`).Scan(&before); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrations.Run(ctx, db); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	var after int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM schema_migrations`).Scan(&after); err != nil {
+		t.Fatal(err)
+	}
+	if before != after {
+		t.Errorf("schema_migrations grew from %d to %d rows on a no-op Run", before, after)
+	}
+
+	names, _ := fs.Glob(migrations.Files, "sql/*.sql")
+	if after != len(names) {
+		t.Errorf("%d migrations recorded, want %d", after, len(names))
+	}
+}
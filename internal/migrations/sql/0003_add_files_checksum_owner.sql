@@ -0,0 +1,3 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS checksum CHAR(64);
+ALTER TABLE files ADD COLUMN IF NOT EXISTS corrupt BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE files ADD COLUMN IF NOT EXISTS owner_id VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS compressed BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS storage_key VARCHAR(64);
+-- Rows from before pluggable storage keep their content in files.content
+UPDATE files SET storage_key = 'legacy-' || id WHERE storage_key IS NULL;
+ALTER TABLE files ALTER COLUMN storage_key SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_files_storage_key ON files(storage_key);
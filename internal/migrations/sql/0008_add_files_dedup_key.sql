@@ -0,0 +1,5 @@
+-- dedup_key holds the checksum of files eligible for dedup. It is separate
+-- from checksum so rows uploaded before dedup, which may contain duplicates,
+-- don't block the unique index.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS dedup_key CHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_files_owner_dedup_key ON files(owner_id, dedup_key) WHERE deleted_at IS NULL;
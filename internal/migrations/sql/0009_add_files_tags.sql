@@ -0,0 +1 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_files_expires_at ON files(expires_at) WHERE expires_at IS NOT NULL;
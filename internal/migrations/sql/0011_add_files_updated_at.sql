@@ -0,0 +1 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
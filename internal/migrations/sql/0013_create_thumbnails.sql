@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS thumbnails (
+    file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    width INTEGER NOT NULL,
+    content BYTEA NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (file_id, width)
+);
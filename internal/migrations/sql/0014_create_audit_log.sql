@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(32) NOT NULL,
+    file_id INTEGER,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    remote_addr VARCHAR(255) NOT NULL DEFAULT '',
+    at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_file_id ON audit_log(file_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id VARCHAR(32) PRIMARY KEY,
+    owner_id VARCHAR(255) NOT NULL DEFAULT '',
+    filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    size BIGINT NOT NULL,
+    received BIGINT NOT NULL DEFAULT 0,
+    data BYTEA NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    owner_id VARCHAR(255) NOT NULL DEFAULT '',
+    key VARCHAR(255) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    status INTEGER,
+    location TEXT,
+    body TEXT,
+    file_id BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (owner_id, key)
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
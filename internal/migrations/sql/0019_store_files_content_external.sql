@@ -0,0 +1,4 @@
+-- Content is compressed by the service where worthwhile, storing it
+-- uncompressed in TOAST lets substring() read a slice without loading the
+-- whole value, which chunked downloads rely on. Applies to newly written rows.
+ALTER TABLE files ALTER COLUMN content SET STORAGE EXTERNAL;
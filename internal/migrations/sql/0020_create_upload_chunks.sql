@@ -0,0 +1,14 @@
+-- Chunks of resumable uploads get a row each, appending to a single BYTEA
+-- rewrote everything received so far on every chunk
+CREATE TABLE IF NOT EXISTS upload_chunks (
+    upload_id VARCHAR(32) NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    start_offset BIGINT NOT NULL,
+    data BYTEA NOT NULL,
+    PRIMARY KEY (upload_id, start_offset)
+);
+
+INSERT INTO upload_chunks (upload_id, start_offset, data)
+SELECT id, 0, data FROM upload_sessions WHERE received > 0
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS data;
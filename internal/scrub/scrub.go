@@ -0,0 +1,160 @@
+package scrub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"inv/internal/encryption"
+	"inv/internal/logging"
+	"inv/internal/storage"
+)
+
+// uploadGrace is how long a new row is left alone. Rows are inserted before
+// their content is written, so content missing from a younger row is most
+// likely an upload still in progress rather than data loss.
+const uploadGrace = time.Hour
+
+// Scrubber periodically re-hashes stored files and flags rows whose content
+// no longer matches the stored checksum.
+type Scrubber struct {
+	db     *sql.DB
+	store  storage.Storage
+	logger logging.Logger
+	cipher *encryption.Cipher // nil skips encrypted files
+
+	// interval is the pause between full passes, fileDelay the pause between
+	// files within a pass so the scrub doesn't compete with live traffic
+	interval  time.Duration
+	fileDelay time.Duration
+}
+
+// New creates a scrubber
+func New(db *sql.DB, store storage.Storage, logger logging.Logger, cipher *encryption.Cipher, interval, fileDelay time.Duration) *Scrubber {
+	return &Scrubber{db: db, store: store, logger: logger, cipher: cipher, interval: interval, fileDelay: fileDelay}
+}
+
+// Run scrubs until ctx is canceled
+func (s *Scrubber) Run(ctx context.Context) {
+	for {
+		if err := s.Pass(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error(ctx, "scrub pass failed", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// Pass verifies every file with a stored checksum once, except those
+// created within uploadGrace
+func (s *Scrubber) Pass(ctx context.Context) error {
+	var lastID int64
+	for {
+		var (
+			id         int64
+			checksum   string
+			compressed bool
+			encrypted  bool
+			key        string
+		)
+		err := s.db.QueryRowContext(ctx, `
+            SELECT id, checksum, compressed, encrypted, storage_key
+            FROM files
+            WHERE id > $1 AND checksum IS NOT NULL
+              AND created_at < CURRENT_TIMESTAMP - make_interval(secs => $2)
+            ORDER BY id
+            LIMIT 1`, lastID, uploadGrace.Seconds()).Scan(&id, &checksum, &compressed, &encrypted, &key)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("load file after %d: %w", lastID, err)
+		}
+		lastID = id
+
+		if encrypted && s.cipher == nil {
+			continue
+		}
+		if err := s.verify(ctx, id, checksum, compressed, encrypted, key); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.fileDelay):
+		}
+	}
+}
+
+// verify streams the stored content through the hash and flags the row as
+// corrupt when it doesn't match checksum. Compressed content is hashed as it
+// decompresses, since checksums describe the original bytes. Encrypted
+// content that fails to decrypt is flagged as well.
+func (s *Scrubber) verify(ctx context.Context, id int64, checksum string, compressed, encrypted bool, key string) error {
+	content, err := s.store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return s.flag(ctx, id, checksum, "missing content")
+	}
+	if err != nil {
+		return fmt.Errorf("load content of file %d: %w", id, err)
+	}
+	defer content.Close()
+
+	var src io.Reader = content
+	if encrypted {
+		sealed, err := io.ReadAll(content)
+		if err != nil {
+			return fmt.Errorf("read content of file %d: %w", id, err)
+		}
+		plain, err := s.cipher.Open(sealed)
+		if err != nil {
+			return s.flag(ctx, id, checksum, err.Error())
+		}
+		src = bytes.NewReader(plain)
+	}
+	if compressed {
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			return s.flag(ctx, id, checksum, "invalid gzip: "+err.Error())
+		}
+		src = zr
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, src); err != nil {
+		if !compressed {
+			return fmt.Errorf("read content of file %d: %w", id, err)
+		}
+		return s.flag(ctx, id, checksum, "invalid gzip: "+err.Error())
+	}
+	computed := hex.EncodeToString(hash.Sum(nil))
+	if computed == checksum {
+		return nil
+	}
+	return s.flag(ctx, id, checksum, computed)
+}
+
+// flag marks the row as corrupt
+func (s *Scrubber) flag(ctx context.Context, id int64, checksum, computed string) error {
+	s.logger.Error(ctx, "file content does not match checksum",
+		slog.Int64("file_id", id),
+		slog.String("stored", checksum),
+		slog.String("computed", computed),
+	)
+	if _, err := s.db.ExecContext(ctx, `UPDATE files SET corrupt = TRUE WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("flag file %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package scrub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"inv/internal/logging"
+	"inv/internal/storage"
+	"inv/internal/testdb"
+)
+
+func TestPass(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	store, err := storage.NewFS(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := func(b []byte) string {
+		h := sha256.Sum256(b)
+		return hex.EncodeToString(h[:])
+	}
+	gz := func(b []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(b)
+		zw.Close()
+		return buf.Bytes()
+	}
+	original := []byte("original content")
+
+	tests := []struct {
+		name       string
+		stored     []byte // nil leaves the content missing
+		compressed bool
+		encrypted  bool
+		age        time.Duration
+		corrupt    bool
+	}{
+		{name: "intact", stored: original, age: 2 * uploadGrace},
+		{name: "intact compressed", stored: gz(original), compressed: true, age: 2 * uploadGrace},
+		{name: "altered", stored: []byte("altered content"), age: 2 * uploadGrace, corrupt: true},
+		{name: "invalid gzip", stored: original, compressed: true, age: 2 * uploadGrace, corrupt: true},
+		{name: "missing content", age: 2 * uploadGrace, corrupt: true},
+		{name: "upload in progress", age: time.Minute},
+		{name: "encrypted without key", stored: []byte("sealed"), encrypted: true, age: 2 * uploadGrace},
+	}
+	ids := make([]int64, len(tests))
+	for i, tt := range tests {
+		key, _ := storage.NewKey()
+		err := db.QueryRow(`
+            INSERT INTO files (filename, mime_type, size, checksum, compressed, encrypted, storage_key, created_at)
+            VALUES ('scrub.txt', 'text/plain', $1, $2, $3, $4, $5, CURRENT_TIMESTAMP - make_interval(secs => $6))
+            RETURNING id`,
+			len(original), sum(original), tt.compressed, tt.encrypted, key, tt.age.Seconds()).Scan(&ids[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := ids[i]
+		t.Cleanup(func() { db.Exec(`DELETE FROM files WHERE id = $1`, id) })
+		if tt.stored != nil {
+			if err := store.Put(ctx, key, bytes.NewReader(tt.stored)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := New(db, store, logging.Discard, nil, time.Hour, 0).Pass(ctx); err != nil {
+		t.Fatalf("Pass: %v", err)
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var corrupt bool
+			if err := db.QueryRow(`SELECT corrupt FROM files WHERE id = $1`, ids[i]).Scan(&corrupt); err != nil {
+				t.Fatal(err)
+			}
+			if corrupt != tt.corrupt {
+				t.Errorf("corrupt = %v, want %v", corrupt, tt.corrupt)
+			}
+		})
+	}
+}
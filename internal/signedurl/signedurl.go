@@ -0,0 +1,32 @@
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Sign returns the hex HMAC-SHA256 authorizing downloads of file id until
+// expires
+func Sign(secret string, id int64, expires time.Time) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(id, 10) + ":" + strconv.FormatInt(expires.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether sig is a valid signature for file id with the
+// expires unix timestamp, and that it hasn't expired at now
+func Verify(secret string, id int64, expires, sig string, now time.Time) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > exp {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	want, _ := hex.DecodeString(Sign(secret, id, time.Unix(exp, 0)))
+	return hmac.Equal(got, want)
+}
@@ -0,0 +1,54 @@
+package signedurl
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	now := time.Unix(1_700_000_000, 0)
+	expires := now.Add(time.Minute)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	sig := Sign(secret, 42, expires)
+
+	tests := []struct {
+		name    string
+		secret  string
+		id      int64
+		expires string
+		sig     string
+		now     time.Time
+		want    bool
+	}{
+		{name: "valid", secret: secret, id: 42, expires: exp, sig: sig, now: now, want: true},
+		{name: "at expiry", secret: secret, id: 42, expires: exp, sig: sig, now: expires, want: true},
+		{name: "expired", secret: secret, id: 42, expires: exp, sig: sig, now: expires.Add(time.Second)},
+		{name: "other file", secret: secret, id: 43, expires: exp, sig: sig, now: now},
+		{name: "extended expiry", secret: secret, id: 42, expires: strconv.FormatInt(expires.Unix()+3600, 10), sig: sig, now: now},
+		{name: "other secret", secret: secret + "x", id: 42, expires: exp, sig: sig, now: now},
+		{name: "malformed expiry", secret: secret, id: 42, expires: "soon", sig: sig, now: now},
+		{name: "malformed signature", secret: secret, id: 42, expires: exp, sig: "zz", now: now},
+		{name: "truncated signature", secret: secret, id: 42, expires: exp, sig: sig[:32], now: now},
+		{name: "empty signature", secret: secret, id: 42, expires: exp, now: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.id, tt.expires, tt.sig, tt.now); got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignDeterministic(t *testing.T) {
+	expires := time.Unix(1_700_000_000, 0)
+	if Sign("k", 1, expires) != Sign("k", 1, expires) {
+		t.Error("Sign isn't deterministic")
+	}
+	// "1:23..." and "12:3..." must not collide
+	if Sign("k", 1, time.Unix(23, 0)) == Sign("k", 12, time.Unix(3, 0)) {
+		t.Error("signatures of different id/expiry pairs collide")
+	}
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxDBContentBytes is the largest value Postgres holds in a BYTEA field.
+// Memory on the server usually runs out well before, large files belong in
+// the fs or s3 backend.
+const MaxDBContentBytes = 1<<30 - 1
+
+// DB keeps content in the files.content BYTEA column of the row with the
+// matching storage_key, so the row must exist before Put.
+type DB struct {
+	db *sql.DB
+}
+
+// NewDB creates a database backed storage
+func NewDB(db *sql.DB) *DB {
+	return &DB{db: db}
+}
+
+// Put implements Storage
+func (s *DB) Put(ctx context.Context, key string, r io.Reader) error {
+	return put(ctx, s.db, key, r)
+}
+
+// PutTx implements TxPutter
+func (s *DB) PutTx(ctx context.Context, tx *sql.Tx, key string, r io.Reader) error {
+	return put(ctx, tx, key, r)
+}
+
+// execer is the part of *sql.DB and *sql.Tx put needs
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// put writes the content read from r into the row with storage key key
+func put(ctx context.Context, db execer, key string, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read content: %w", err)
+	}
+	res, err := db.ExecContext(ctx, `UPDATE files SET content = $2 WHERE storage_key = $1`, key, content)
+	if err != nil {
+		return fmt.Errorf("store content: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("store content: no file row with storage key %q", key)
+	}
+	return nil
+}
+
+// dbChunkBytes is how much content Get's reader fetches per query
+const dbChunkBytes = 1 << 20
+
+// Get implements Storage. The content is read in chunks of dbChunkBytes
+// within a read-only snapshot, so memory stays bounded whatever the file
+// size and a concurrent Put can't mix old and new content. The snapshot
+// holds a connection until the reader is closed.
+func (s *DB) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("load content: %w", err)
+	}
+
+	// content is nullable, e.g. while a row waits for its Put or after
+	// Delete, so NULL is asked for explicitly rather than inferred
+	var (
+		size int64
+		null bool
+	)
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(octet_length(content), 0), content IS NULL FROM files WHERE storage_key = $1`, key).Scan(&size, &null)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && null) {
+		tx.Rollback()
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("load content: %w", err)
+	}
+	return &chunkReader{ctx: ctx, tx: tx, key: key, size: size}, nil
+}
+
+// chunkReader reads BYTEA content a slice at a time
+type chunkReader struct {
+	ctx    context.Context
+	tx     *sql.Tx
+	key    string
+	size   int64
+	offset int64 // of the next chunk to fetch
+	buf    []byte
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(c.buf) == 0 {
+		if c.offset >= c.size {
+			return 0, io.EOF
+		}
+		// substring counts from 1
+		err := c.tx.QueryRowContext(c.ctx, `SELECT substring(content FROM $2 FOR $3) FROM files WHERE storage_key = $1`,
+			c.key, c.offset+1, dbChunkBytes).Scan(&c.buf)
+		if err != nil {
+			return 0, fmt.Errorf("load content: %w", err)
+		}
+		if len(c.buf) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		c.offset += int64(len(c.buf))
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// Close ends the snapshot, releasing its connection
+func (c *chunkReader) Close() error {
+	err := c.tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
+
+// Delete implements Storage
+func (s *DB) Delete(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE files SET content = NULL WHERE storage_key = $1`, key)
+	if err != nil {
+		return fmt.Errorf("delete content: %w", err)
+	}
+	return nil
+}
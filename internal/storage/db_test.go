@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"inv/internal/testdb"
+)
+
+// newRow inserts a files row without content and returns its storage key,
+// the handlers create the row before Put the same way
+func newRow(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO files (filename, mime_type, size, storage_key) VALUES ('test.bin', 'application/octet-stream', 0, $1)`, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM files WHERE storage_key = $1`, key) })
+	return key
+}
+
+func TestDB(t *testing.T) {
+	db := testdb.Open(t)
+	s := NewDB(db)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "empty", content: []byte{}},
+		{name: "small", content: []byte("hello")},
+		{name: "several chunks", content: bytes.Repeat([]byte("0123456789abcdef"), dbChunkBytes/8+3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := newRow(t, db)
+			if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get before Put error = %v, want ErrNotFound", err)
+			}
+			if err := s.Put(ctx, key, bytes.NewReader(tt.content)); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if got := readAll(t, s, key); got != string(tt.content) {
+				t.Errorf("Get returned %d bytes, want %d", len(got), len(tt.content))
+			}
+			if err := s.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestDBPutWithoutRow(t *testing.T) {
+	s := NewDB(testdb.Open(t))
+	key, _ := NewKey()
+	if err := s.Put(context.Background(), key, strings.NewReader("x")); err == nil {
+		t.Error("Put succeeded without a files row")
+	}
+}
+
+func TestDBPutTx(t *testing.T) {
+	db := testdb.Open(t)
+	s := NewDB(db)
+	ctx := context.Background()
+	key := newRow(t, db)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutTx(ctx, tx, key, strings.NewReader("rolled back")); err != nil {
+		t.Fatalf("PutTx: %v", err)
+	}
+	tx.Rollback()
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after rollback error = %v, want ErrNotFound", err)
+	}
+
+	tx, err = db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutTx(ctx, tx, key, strings.NewReader("committed")); err != nil {
+		t.Fatalf("PutTx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, s, key); got != "committed" {
+		t.Errorf("Get = %q, want committed", got)
+	}
+}
+
+func TestDBGetReadsSnapshot(t *testing.T) {
+	db := testdb.Open(t)
+	s := NewDB(db)
+	ctx := context.Background()
+	key := newRow(t, db)
+
+	old := bytes.Repeat([]byte("a"), 2*dbChunkBytes)
+	if err := s.Put(ctx, key, bytes.NewReader(old)); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	first := make([]byte, 10)
+	if _, err := io.ReadFull(rc, first); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, key, strings.NewReader("replaced")); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := append(first, rest...); !bytes.Equal(got, old) {
+		t.Errorf("reader mixed content across a concurrent Put: got %d bytes", len(got))
+	}
+}
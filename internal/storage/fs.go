@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FS keeps content as one file per key in a local directory
+type FS struct {
+	dir string
+}
+
+// NewFS creates a filesystem storage rooted at dir, creating it if needed
+func NewFS(dir string) (*FS, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create storage dir: %w", err)
+	}
+	return &FS{dir: dir}, nil
+}
+
+// Put implements Storage. Content is written to a temp file and renamed
+// into place so readers never see a partial file.
+func (s *FS) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".put-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write content: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write content: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("store content: %w", err)
+	}
+	return nil
+}
+
+// Get implements Storage
+func (s *FS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open content: %w", err)
+	}
+	return f, nil
+}
+
+// Delete implements Storage
+func (s *FS) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete content: %w", err)
+	}
+	return nil
+}
+
+// path maps key to a file in dir, rejecting keys that could escape it
+func (s *FS) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\.`) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
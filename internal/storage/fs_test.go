@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFS(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewFS(filepath.Join(dir, "content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of a missing key error = %v, want ErrNotFound", err)
+	}
+	for _, content := range []string{"first", "second"} {
+		if err := s.Put(ctx, key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got := readAll(t, s, key); got != content {
+			t.Errorf("Get = %q, want %q", got, content)
+		}
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing key: %v", err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(dir, "content"))
+	if len(entries) != 0 {
+		t.Errorf("left %d files behind, want none", len(entries))
+	}
+}
+
+func TestFSRejectsKeys(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFS(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "..", "../escape", "a/b", `a\b`, ".hidden"} {
+		t.Run(key, func(t *testing.T) {
+			if err := s.Put(ctx, key, strings.NewReader("x")); err == nil {
+				t.Error("Put accepted the key")
+			}
+			if _, err := s.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+				t.Errorf("Get error = %v, want an invalid key error", err)
+			}
+			if err := s.Delete(ctx, key); err == nil {
+				t.Error("Delete accepted the key")
+			}
+		})
+	}
+}
+
+func TestFSFailedPutKeepsContent(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFS(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "k", strings.NewReader("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "k", io.MultiReader(strings.NewReader("new"), errReader{})); err == nil {
+		t.Fatal("Put succeeded with a failing reader")
+	}
+	if got := readAll(t, s, "k"); got != "old" {
+		t.Errorf("Get after a failed Put = %q, want the previous content", got)
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	a, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewKey()
+	if a == b || len(a) != 32 || strings.ContainsAny(a, `/\.`) {
+		t.Errorf("NewKey = %q, %q", a, b)
+	}
+}
+
+// errReader fails every read
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+// readAll returns the content s stores under key
+func readAll(t *testing.T, s Storage, key string) string {
+	t.Helper()
+	rc, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read content: %v", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 keeps content as one object per key in a bucket, under an optional prefix
+type S3 struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3 creates an S3 storage
+func NewS3(client *s3.Client, bucket, prefix string) *S3 {
+	return &S3{client: client, bucket: bucket, prefix: prefix}
+}
+
+// Put implements Storage. Readers that can't seek are buffered in memory
+// since the SDK needs to sign the payload.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) error {
+	if _, ok := r.(io.ReadSeeker); !ok {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("read content: %w", err)
+		}
+		r = bytes.NewReader(content)
+	}
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+		Body:   r,
+	})
+	if err != nil {
+		return fmt.Errorf("put object: %w", err)
+	}
+	return nil
+}
+
+// Get implements Storage
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get object: %w", err)
+	}
+	return out.Body, nil
+}
+
+// PresignGet implements Presigner
+func (s *S3) PresignGet(ctx context.Context, key string, ttl time.Duration, filename, mimeType string) (string, error) {
+	req, err := s3.NewPresignClient(s.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(s.prefix + key),
+		ResponseContentType:        aws.String(mimeType),
+		ResponseContentDisposition: aws.String(mime.FormatMediaType("attachment", map[string]string{"filename": filename})),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("presign object: %w", err)
+	}
+	return req.URL, nil
+}
+
+// Delete implements Storage
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	if err != nil {
+		return fmt.Errorf("delete object: %w", err)
+	}
+	return nil
+}
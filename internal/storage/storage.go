@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned by Get when no content is stored under the key
+var ErrNotFound = errors.New("content not found")
+
+// Storage keeps file content addressed by a storage key, while the files
+// table keeps only the metadata and the key.
+type Storage interface {
+	// Put stores the content read from r under key, replacing any previous content
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the content stored under key, the caller must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key, deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by backends clients can download from directly
+type Presigner interface {
+	// PresignGet returns a URL serving the content stored under key for
+	// ttl, with the given name and media type in the response headers
+	PresignGet(ctx context.Context, key string, ttl time.Duration, filename, mimeType string) (string, error)
+}
+
+// TxPutter is implemented by backends keeping content in the database, whose
+// writes can join a transaction. Put then sees rows tx created or re-keyed.
+type TxPutter interface {
+	// PutTx is Put as part of tx
+	PutTx(ctx context.Context, tx *sql.Tx, key string, r io.Reader) error
+}
+
+// NewKey returns a random storage key, safe to use as a file or object name
+func NewKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
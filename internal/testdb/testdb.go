@@ -0,0 +1,48 @@
+// Package testdb connects tests to a disposable Postgres database named by
+// TEST_DATABASE_URL, migrated to the latest schema. Tests using it are
+// skipped when the variable is unset.
+package testdb
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL driver registered as "pgx"
+	_ "github.com/lib/pq"              // PostgreSQL driver registered as "postgres"
+
+	"inv/internal/config"
+	"inv/internal/migrations"
+)
+
+// Open returns a connection to the test database, closed when tb ends.
+// TEST_DB_DRIVER selects the driver, postgres by default. Tests share the
+// database, so they should only touch rows they created.
+func Open(tb testing.TB) *sql.DB {
+	tb.Helper()
+	driver := os.Getenv("TEST_DB_DRIVER")
+	if driver == "" {
+		driver = config.DBDriverPQ
+	}
+	return OpenDriver(tb, driver)
+}
+
+// OpenDriver is Open through the given driver
+func OpenDriver(tb testing.TB, driver string) *sql.DB {
+	tb.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open(driver, url)
+	if err != nil {
+		tb.Fatalf("open test database: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if err := migrations.Run(context.Background(), db); err != nil {
+		tb.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"inv/internal/logging"
+)
+
+// Payload is the JSON body posted for an uploaded file
+type Payload struct {
+	ID        int64     `json:"id"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PanicPayload is the JSON body posted for a panic recovered while serving
+// a request
+type PanicPayload struct {
+	Event string    `json:"event"` // always "panic"
+	Error string    `json:"error"`
+	Stack string    `json:"stack"`
+	At    time.Time `json:"at"`
+}
+
+// Notifier posts payloads to a webhook URL in the background, retrying
+// non-2xx responses with exponential backoff. Failures are only logged.
+type Notifier struct {
+	url     string
+	client  *http.Client
+	logger  logging.Logger
+	timeout time.Duration
+	retries int
+
+	// Pending deliveries, Close waits for them and cancels ctx when it
+	// gives up
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	pending sync.WaitGroup
+	closed  bool
+}
+
+// New creates a notifier, timeout bounds each delivery including retries
+func New(url string, logger logging.Logger, timeout time.Duration, retries int) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Notifier{
+		url:     url,
+		client:  &http.Client{},
+		logger:  logger,
+		timeout: timeout,
+		retries: retries,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Notify delivers p asynchronously without blocking the caller, payloads
+// after Close are dropped
+func (n *Notifier) Notify(p Payload) {
+	n.send(p, slog.Int64("file_id", p.ID))
+}
+
+// NotifyPanic delivers p like Notify
+func (n *Notifier) NotifyPanic(p PanicPayload) {
+	p.Event = "panic"
+	n.send(p, slog.String("event", p.Event))
+}
+
+// send delivers the JSON encoding of v in the background, attr identifies
+// it in logs
+func (n *Notifier) send(v any, attr slog.Attr) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		n.logger.Warn(context.Background(), "webhook dropped after shutdown", attr)
+		return
+	}
+	n.pending.Add(1)
+	go func() {
+		defer n.pending.Done()
+		ctx, cancel := context.WithTimeout(n.ctx, n.timeout)
+		defer cancel()
+		if err := n.deliver(ctx, v); err != nil {
+			n.logger.Error(ctx, "webhook delivery failed",
+				attr,
+				slog.String("error", err.Error()),
+			)
+		}
+	}()
+}
+
+// Close stops accepting payloads and waits for pending deliveries. When ctx
+// ends first the remaining deliveries are canceled and ctx's error returned.
+func (n *Notifier) Close(ctx context.Context) error {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.pending.Wait()
+		close(done)
+	}()
+	defer n.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		n.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// deliver posts v, retrying up to n.retries times
+func (n *Notifier) deliver(ctx context.Context, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode payload: %w", err)
+	}
+
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err = n.post(ctx, body)
+		if err == nil || attempt >= n.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last attempt: %v)", ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"inv/internal/logging"
+)
+
+// receiver records the bodies posted to it, answering with the statuses
+// in order and 200 once they run out
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	json.NewDecoder(r.Body).Decode(&body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.bodies = append(rc.bodies, body)
+	if len(rc.statuses) > 0 {
+		w.WriteHeader(rc.statuses[0])
+		rc.statuses = rc.statuses[1:]
+	}
+}
+
+func TestNotify(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		attempts int
+	}{
+		{name: "delivered", attempts: 1},
+		{name: "retried", statuses: []int{http.StatusBadGateway}, retries: 2, attempts: 2},
+		{name: "gives up", statuses: []int{500, 500, 500}, retries: 1, attempts: 2},
+		{name: "no retries", statuses: []int{500}, attempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{statuses: tt.statuses}
+			srv := httptest.NewServer(rc)
+			defer srv.Close()
+
+			n := New(srv.URL, logging.Discard, 5*time.Second, tt.retries)
+			n.Notify(Payload{ID: 7, Filename: "a.txt", Size: 3})
+			if err := n.Close(context.Background()); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			if len(rc.bodies) != tt.attempts {
+				t.Fatalf("attempts = %d, want %d", len(rc.bodies), tt.attempts)
+			}
+			var got Payload
+			if err := json.Unmarshal(rc.bodies[0], &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != 7 || got.Filename != "a.txt" || got.Size != 3 {
+				t.Errorf("payload = %+v", got)
+			}
+		})
+	}
+}
+
+func TestNotifyPanic(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	n := New(srv.URL, logging.Discard, 5*time.Second, 0)
+	n.NotifyPanic(PanicPayload{Error: "boom"})
+	n.Close(context.Background())
+
+	if len(rc.bodies) != 1 {
+		t.Fatalf("deliveries = %d, want 1", len(rc.bodies))
+	}
+	var got PanicPayload
+	json.Unmarshal(rc.bodies[0], &got)
+	if got.Event != "panic" || got.Error != "boom" {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+func TestNotifyAfterClose(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { calls.Add(1) }))
+	defer srv.Close()
+
+	n := New(srv.URL, logging.Discard, time.Second, 0)
+	n.Close(context.Background())
+	n.Notify(Payload{ID: 1})
+	n.Close(context.Background())
+	if calls.Load() != 0 {
+		t.Errorf("delivered %d payloads after Close", calls.Load())
+	}
+}
+
+func TestCloseCancelsPending(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	n := New(srv.URL, logging.Discard, time.Minute, 0)
+	n.Notify(Payload{ID: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Close took %s, want it to give up with ctx", elapsed)
+	}
+}